@@ -8,10 +8,15 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"hookrunner/internal/config"
 	"hookrunner/internal/daemon"
+	"hookrunner/internal/dedup"
 	"hookrunner/internal/funnel"
+	"hookrunner/internal/github"
+	"hookrunner/internal/journal"
+	"hookrunner/internal/queue"
 	"hookrunner/internal/server"
 	"hookrunner/internal/webhook"
 )
@@ -39,6 +44,22 @@ func main() {
 		*configPath = config.DefaultPath()
 	}
 
+	switch flag.Arg(0) {
+	case "":
+	case "replay":
+		if err := runReplay(*configPath, flag.Args()[1:]); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	case "runs":
+		if err := runRuns(*configPath, flag.Args()[1:]); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	default:
+		log.Fatalf("Unknown command %q", flag.Arg(0))
+	}
+
 	if *init_ {
 		if err := config.GenerateDefault(*configPath); err != nil {
 			log.Fatalf("Failed to generate config: %v", err)
@@ -65,6 +86,9 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if err := queue.ValidateGroups(cfg); err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
 
 	if *port != 0 {
 		cfg.Port = *port
@@ -97,8 +121,37 @@ func main() {
 		}
 	}
 
-	webhookHandler := webhook.Handler(cfg)
-	srv := server.New(cfg.Port, webhookHandler)
+	client, err := newGitHubClient(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up GitHub client: %v", err)
+	}
+	q := newQueue(cfg, client)
+	if err := q.Persist(config.StatePath(cfg, "queue.json")); err != nil {
+		log.Printf("WARNING: Failed to restore job queue: %v", err)
+	}
+
+	deliveries, err := dedup.Open(config.StatePath(cfg, "deliveries"), cfg.DeliveryHistory)
+	if err != nil {
+		log.Fatalf("Failed to open delivery store: %v", err)
+	}
+
+	opts := webhook.Options{
+		Queue:      q,
+		Deliveries: deliveries,
+		Journal:    journal.Open(config.StatePath(cfg, "events")),
+		Teams:      github.NewTeamCache(client, teamCacheTTL),
+	}
+	srv := server.New(cfg.Port, webhook.Handler(cfg, opts))
+	if cfg.GitLab.WebhookToken != "" {
+		srv.Handle("/webhook/gitlab", webhook.GitLabHandler(cfg, opts))
+	}
+	if cfg.Gitea.WebhookSecret != "" {
+		srv.Handle("/webhook/gitea", webhook.GiteaHandler(cfg, opts))
+	}
+	for name, src := range cfg.Sources {
+		srv.Handle(src.Path, webhook.SourceHandler(cfg, name, webhook.Options{Queue: q}))
+		log.Printf("Source %q listening on %s", name, src.Path)
+	}
 	go func() {
 		if err := srv.Start(); err != nil {
 			log.Printf("Server error: %v", err)
@@ -123,6 +176,10 @@ func main() {
 
 	srv.Shutdown()
 
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	q.Shutdown(shutdownCtx)
+	shutdownCancel()
+
 	if fp != nil {
 		funnel.Stop(fp)
 	}
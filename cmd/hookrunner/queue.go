@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/github"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/report"
+	"hookrunner/internal/runs"
+)
+
+// teamCacheTTL is how long team membership lookups are trusted.
+const teamCacheTTL = 10 * time.Minute
+
+// newQueue builds the job queue used by both the server and replay. Every
+// run is recorded in the run history and reported back to GitHub through
+// client as configured per workflow.
+func newQueue(cfg *config.Config, client *github.Client) *queue.Queue {
+	store := runs.Open(config.StatePath(cfg, "runs"), cfg.Runs)
+
+	reactor := report.NewReactor(client)
+	checker := report.NewChecker(client)
+
+	exec := store.Execute
+	exec = report.NewCommenter(client, store.LogPath).Wrap(exec)
+	exec = reactor.Wrap(exec)
+	exec = checker.Wrap(exec)
+
+	q := queue.New(cfg, exec)
+	q.OnSubmit(reactor.Queued)
+	q.OnSubmit(checker.Queued)
+	q.OnDrop(reactor.Dropped)
+	q.OnDrop(checker.Dropped)
+	return q
+}
+
+// newGitHubClient authenticates as the configured GitHub App if there is one,
+// and with github.token otherwise.
+func newGitHubClient(cfg *config.Config) (*github.Client, error) {
+	if cfg.GitHubApp.AppID == 0 {
+		return github.NewClient(cfg.GitHub.APIURL, cfg.GitHub.Token), nil
+	}
+	key, err := github.LoadPrivateKey(config.ExpandTilde(cfg.GitHubApp.PrivateKeyPath))
+	if err != nil {
+		return nil, fmt.Errorf("github_app: %w", err)
+	}
+	app := github.NewApp(cfg.GitHub.APIURL, cfg.GitHubApp.AppID, key)
+	return github.NewAppClient(cfg.GitHub.APIURL, app, cfg.GitHubApp.InstallationID), nil
+}
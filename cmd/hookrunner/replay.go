@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/github"
+	"hookrunner/internal/journal"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/webhook"
+)
+
+// runReplay re-runs a journaled delivery in this process and waits for the
+// workflows it matches to finish.
+func runReplay(configPath string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: hookrunner replay <delivery-id>")
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if err := queue.ValidateGroups(cfg); err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	entry, err := journal.Open(config.StatePath(cfg, "events")).Find(args[0])
+	if err != nil {
+		return err
+	}
+	log.Printf("Replaying delivery %s (%s, received %s)",
+		entry.DeliveryID, entry.Event, entry.Time.Format("2006-01-02 15:04:05"))
+
+	client, err := newGitHubClient(cfg)
+	if err != nil {
+		return err
+	}
+	q := newQueue(cfg, client)
+	workflows, err := webhook.Replay(cfg, webhook.Options{
+		Queue: q,
+		Teams: github.NewTeamCache(client, teamCacheTTL),
+	}, entry)
+	if err != nil {
+		return fmt.Errorf("replaying delivery: %w", err)
+	}
+	if len(workflows) == 0 {
+		fmt.Println("No workflow matched")
+		return nil
+	}
+
+	q.Wait()
+	return nil
+}
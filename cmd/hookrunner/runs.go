@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/runs"
+)
+
+func runRuns(configPath string, args []string) error {
+	usage := fmt.Errorf("usage: hookrunner runs list|show <id>|logs <id>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	store := runs.Open(config.StatePath(cfg, "runs"), cfg.Runs)
+
+	switch args[0] {
+	case "list":
+		return runsList(store, args[1:])
+	case "show":
+		if len(args) != 2 {
+			return usage
+		}
+		return runsShow(store, args[1])
+	case "logs":
+		if len(args) != 2 {
+			return usage
+		}
+		return runsLogs(store, args[1])
+	default:
+		return usage
+	}
+}
+
+func runsList(store *runs.Store, args []string) error {
+	fs := flag.NewFlagSet("runs list", flag.ContinueOnError)
+	workflowName := fs.String("workflow", "", "Only show runs of this workflow")
+	repo := fs.String("repo", "", "Only show runs for this repository (org/repo)")
+	pr := fs.String("pr", "", "Only show runs for this PR or issue number")
+	limit := fs.Int("limit", 20, "Maximum number of runs to show (0 = all)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	list, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tWORKFLOW\tREPO\tPR\tAUTHOR\tSTARTED\tDURATION\tSTATUS")
+	shown := 0
+	for _, r := range list {
+		if *workflowName != "" && r.Workflow != *workflowName {
+			continue
+		}
+		if *repo != "" && r.Repo != *repo {
+			continue
+		}
+		if *pr != "" && r.PRNumber != *pr {
+			continue
+		}
+		if *limit > 0 && shown >= *limit {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.ID, r.Workflow, r.Repo, r.PRNumber, r.Author,
+			r.Start.Local().Format("2006-01-02 15:04:05"), formatDuration(r), r.Status())
+		shown++
+	}
+	return tw.Flush()
+}
+
+func runsShow(store *runs.Store, id string) error {
+	r, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", r.ID)
+	fmt.Fprintf(tw, "Workflow:\t%s\n", r.Workflow)
+	fmt.Fprintf(tw, "Repository:\t%s\n", r.Repo)
+	fmt.Fprintf(tw, "PR/Issue:\t%s\n", r.PRNumber)
+	fmt.Fprintf(tw, "Author:\t%s\n", r.Author)
+	fmt.Fprintf(tw, "Event:\t%s\n", r.EventType)
+	fmt.Fprintf(tw, "Delivery:\t%s\n", r.Delivery)
+	fmt.Fprintf(tw, "Started:\t%s\n", r.Start.Local().Format(time.RFC3339))
+	if !r.End.IsZero() {
+		fmt.Fprintf(tw, "Finished:\t%s\n", r.End.Local().Format(time.RFC3339))
+	}
+	fmt.Fprintf(tw, "Duration:\t%s\n", formatDuration(r))
+	fmt.Fprintf(tw, "Status:\t%s\n", r.Status())
+	fmt.Fprintf(tw, "Exit code:\t%d\n", r.ExitCode)
+	fmt.Fprintf(tw, "Timed out:\t%t\n", r.TimedOut)
+	if r.Error != "" {
+		fmt.Fprintf(tw, "Error:\t%s\n", r.Error)
+	}
+	fmt.Fprintf(tw, "Output:\t%s (%d bytes", r.OutputPath, r.OutputBytes)
+	if r.OutputTruncated {
+		fmt.Fprintf(tw, ", truncated")
+	}
+	fmt.Fprintf(tw, ")\n")
+	return tw.Flush()
+}
+
+func runsLogs(store *runs.Store, id string) error {
+	r, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+	if r.OutputPath == "" {
+		return fmt.Errorf("run %s has no output", r.ID)
+	}
+	f, err := os.Open(r.OutputPath)
+	if err != nil {
+		return fmt.Errorf("opening output: %w", err)
+	}
+	defer f.Close()
+	_, err = io.Copy(os.Stdout, f)
+	return err
+}
+
+func formatDuration(r *runs.Run) string {
+	if r.End.IsZero() {
+		return "-"
+	}
+	return r.End.Sub(r.Start).Round(100 * time.Millisecond).String()
+}
@@ -2,4 +2,4 @@ module hookrunner
 
 go 1.25.6
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,185 @@
+// Package command parses slash commands such as
+// "/cc review --model=opus --focus security src/" out of comment bodies.
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"hookrunner/internal/config"
+)
+
+// Invocation is a parsed slash command. Flags holds every flag declared by
+// the workflow, with defaults filled in and bool flags set to "true" or
+// "false".
+type Invocation struct {
+	Name  string
+	Args  []string
+	Flags map[string]string
+}
+
+// Find returns the first line of body on which trigger matches and is
+// followed by wf.CommandName, parsed against wf's command_args and
+// command_flags. It returns nil and no error when no line invokes the
+// command, and an error when the invocation does not fit the schema.
+func Find(body string, trigger *regexp.Regexp, wf config.WorkflowConfig) (*Invocation, error) {
+	for _, line := range strings.Split(body, "\n") {
+		loc := trigger.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+		tokens, err := split(line[loc[1]:])
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) == 0 || !strings.EqualFold(tokens[0], wf.CommandName) {
+			continue
+		}
+		return parse(tokens[1:], wf)
+	}
+	return nil, nil
+}
+
+// split breaks s into words on whitespace. Single or double quotes group
+// words, so --focus "error handling" is one flag value.
+func split(s string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+		quote rune
+		in    bool
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, in = r, true
+		case unicode.IsSpace(r):
+			if in {
+				words = append(words, word.String())
+				word.Reset()
+				in = false
+			}
+		default:
+			word.WriteRune(r)
+			in = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if in {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func parse(tokens []string, wf config.WorkflowConfig) (*Invocation, error) {
+	inv := &Invocation{Name: wf.CommandName, Flags: map[string]string{}}
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok == "--" {
+			inv.Args = append(inv.Args, tokens[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(tok, "--") {
+			inv.Args = append(inv.Args, tok)
+			continue
+		}
+		name, value, hasValue := strings.Cut(tok[2:], "=")
+		flag, ok := wf.CommandFlags[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown flag --%s", name)
+		}
+		if _, seen := inv.Flags[name]; seen {
+			return nil, fmt.Errorf("flag --%s given more than once", name)
+		}
+		if !hasValue {
+			if flag.Type == "bool" {
+				value = "true"
+			} else if i+1 < len(tokens) {
+				i++
+				value = tokens[i]
+			} else {
+				return nil, fmt.Errorf("flag --%s needs a value", name)
+			}
+		}
+		v, err := checkValue(flag, value)
+		if err != nil {
+			return nil, fmt.Errorf("flag --%s: %w", name, err)
+		}
+		inv.Flags[name] = v
+	}
+
+	names := make([]string, 0, len(wf.CommandFlags))
+	for name := range wf.CommandFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := inv.Flags[name]; ok {
+			continue
+		}
+		flag := wf.CommandFlags[name]
+		if flag.Required {
+			return nil, fmt.Errorf("missing required flag --%s", name)
+		}
+		v := flag.Default
+		if flag.Type == "bool" {
+			b, _ := strconv.ParseBool(v)
+			v = strconv.FormatBool(b)
+		}
+		inv.Flags[name] = v
+	}
+
+	if n := len(inv.Args); n < wf.CommandArgs.Min || (wf.CommandArgs.Max > 0 && n > wf.CommandArgs.Max) {
+		return nil, fmt.Errorf("got %d arguments, want %s", n, argRange(wf.CommandArgs))
+	}
+	return inv, nil
+}
+
+// checkValue validates value against flag's type and allowed values and
+// returns it in canonical form.
+func checkValue(flag config.CommandFlag, value string) (string, error) {
+	switch flag.Type {
+	case "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return "", fmt.Errorf("%q is not an integer", value)
+		}
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not true or false", value)
+		}
+		value = strconv.FormatBool(b)
+	}
+	if len(flag.Values) > 0 {
+		for _, v := range flag.Values {
+			if v == value {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("%q is not one of %s", value, strings.Join(flag.Values, ", "))
+	}
+	return value, nil
+}
+
+func argRange(a config.CommandArgsConfig) string {
+	switch {
+	case a.Max == 0:
+		return fmt.Sprintf("at least %d", a.Min)
+	case a.Min == a.Max:
+		return fmt.Sprintf("exactly %d", a.Min)
+	default:
+		return fmt.Sprintf("%d to %d", a.Min, a.Max)
+	}
+}
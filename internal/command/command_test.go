@@ -0,0 +1,90 @@
+package command
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"hookrunner/internal/config"
+)
+
+func TestFind(t *testing.T) {
+	trigger := regexp.MustCompile(`/cc\b`)
+	wf := config.WorkflowConfig{
+		CommandName: "review",
+		CommandArgs: config.CommandArgsConfig{Max: 2},
+		CommandFlags: map[string]config.CommandFlag{
+			"model":   {Values: []string{"opus", "sonnet"}, Default: "sonnet"},
+			"focus":   {},
+			"depth":   {Type: "int", Default: "1"},
+			"dry-run": {Type: "bool"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    *Invocation
+		wantErr string
+	}{
+		{
+			name: "flags and args",
+			body: "Thanks!\n/cc review --model=opus --focus security src/ pkg/\nmore text",
+			want: &Invocation{Name: "review", Args: []string{"src/", "pkg/"},
+				Flags: map[string]string{"model": "opus", "focus": "security", "depth": "1", "dry-run": "false"}},
+		},
+		{
+			name: "defaults, bool flag and quotes",
+			body: `/cc REVIEW --dry-run --focus "error handling"`,
+			want: &Invocation{Name: "review",
+				Flags: map[string]string{"model": "sonnet", "focus": "error handling", "depth": "1", "dry-run": "true"}},
+		},
+		{
+			name: "double dash ends flags",
+			body: "/cc review --depth=3 -- --not-a-flag",
+			want: &Invocation{Name: "review", Args: []string{"--not-a-flag"},
+				Flags: map[string]string{"model": "sonnet", "focus": "", "depth": "3", "dry-run": "false"}},
+		},
+		{name: "other subcommand", body: "/cc deploy --model=opus"},
+		{name: "no trigger", body: "review --model=opus"},
+		{name: "unknown flag", body: "/cc review --color=red", wantErr: "unknown flag --color"},
+		{name: "value not allowed", body: "/cc review --model=gpt", wantErr: "not one of opus, sonnet"},
+		{name: "bad int", body: "/cc review --depth deep", wantErr: "not an integer"},
+		{name: "missing value", body: "/cc review --focus", wantErr: "needs a value"},
+		{name: "repeated flag", body: "/cc review --focus a --focus b", wantErr: "more than once"},
+		{name: "too many args", body: "/cc review a b c", wantErr: "got 3 arguments, want 0 to 2"},
+		{name: "unterminated quote", body: `/cc review --focus "oops`, wantErr: "unterminated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Find(tt.body, trigger, wf)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("required flag", func(t *testing.T) {
+		wf := config.WorkflowConfig{
+			CommandName:  "deploy",
+			CommandArgs:  config.CommandArgsConfig{Min: 1, Max: 1},
+			CommandFlags: map[string]config.CommandFlag{"env": {Required: true}},
+		}
+		if _, err := Find("/cc deploy api", trigger, wf); err == nil || !strings.Contains(err.Error(), "missing required flag --env") {
+			t.Errorf("expected missing flag error, got %v", err)
+		}
+		if _, err := Find("/cc deploy --env prod", trigger, wf); err == nil || !strings.Contains(err.Error(), "want exactly 1") {
+			t.Errorf("expected argument count error, got %v", err)
+		}
+	})
+}
@@ -3,19 +3,107 @@ package config
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"gopkg.in/yaml.v3"
+
+	"hookrunner/internal/jsonpath"
 )
 
 type WorkflowConfig struct {
-	Events  []string `yaml:"events"`
-	Authors []string `yaml:"authors"`
-	Trigger string   `yaml:"trigger"`
-	Command string   `yaml:"command"`
-	Workdir string   `yaml:"workdir"`
-	Timeout int      `yaml:"timeout"`
+	Source            string                 `yaml:"source"`
+	Fields            map[string]string      `yaml:"fields"`
+	Repos             []string               `yaml:"repos"`
+	Events            []string               `yaml:"events"`
+	Actions           []string               `yaml:"actions"`
+	Authors           []string               `yaml:"authors"`
+	AuthorAssociation []string               `yaml:"author_association"`
+	Teams             []string               `yaml:"teams"`
+	IgnoreBots        *bool                  `yaml:"ignore_bots"`
+	IgnoreAuthors     []string               `yaml:"ignore_authors"`
+	Branches          []string               `yaml:"branches"`
+	Paths             []string               `yaml:"paths"`
+	Labels            []string               `yaml:"labels"`
+	Trigger           string                 `yaml:"trigger"`
+	CommandName       string                 `yaml:"command_name"`
+	CommandArgs       CommandArgsConfig      `yaml:"command_args"`
+	CommandFlags      map[string]CommandFlag `yaml:"command_flags"`
+	Command           string                 `yaml:"command"`
+	Workdir           string                 `yaml:"workdir"`
+	Timeout           int                    `yaml:"timeout"`
+	Concurrency       int                    `yaml:"concurrency"`
+	ConcurrencyGroup  string                 `yaml:"concurrency_group"`
+	CancelInProgress  bool                   `yaml:"cancel_in_progress"`
+	AllowRedelivery   bool                   `yaml:"allow_redelivery"`
+	Report            ReportConfig           `yaml:"report"`
+}
+
+type ReportConfig struct {
+	Reactions bool `yaml:"reactions"`
+	Check     bool `yaml:"check"`
+	Comment   bool `yaml:"comment"`
+	Update    bool `yaml:"update"`
+	TailLines int  `yaml:"tail_lines"`
+}
+
+// CommandArgsConfig bounds the number of positional arguments a slash
+// command accepts. Max 0 means no limit.
+type CommandArgsConfig struct {
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+}
+
+// CommandFlag declares one --flag of a slash command. Type is "string"
+// (the default), "int" or "bool".
+type CommandFlag struct {
+	Type     string   `yaml:"type"`
+	Values   []string `yaml:"values"`
+	Required bool     `yaml:"required"`
+	Default  string   `yaml:"default"`
+}
+
+// SourceConfig defines an extra endpoint for JSON webhooks from other
+// tools. The event type is read from EventHeader or EventPath, and Fields
+// maps field names to JSON paths such as $.alert.severity.
+type SourceConfig struct {
+	Path        string            `yaml:"path"`
+	Auth        string            `yaml:"auth"`
+	Secret      string            `yaml:"secret"`
+	Header      string            `yaml:"header"`
+	EventHeader string            `yaml:"event_header"`
+	EventPath   string            `yaml:"event_path"`
+	Fields      map[string]string `yaml:"fields"`
+}
+
+// GitLabConfig enables the /webhook/gitlab endpoint. WebhookToken is the
+// secret token set on the GitLab webhook.
+type GitLabConfig struct {
+	WebhookToken string `yaml:"webhook_token"`
+}
+
+// GiteaConfig enables the /webhook/gitea endpoint for Gitea and Forgejo.
+// WebhookSecret is the secret set on the webhook.
+type GiteaConfig struct {
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+type GitHubConfig struct {
+	Token  string `yaml:"token"`
+	APIURL string `yaml:"api_url"`
+}
+
+// GitHubAppConfig authenticates outbound API calls as a GitHub App
+// installation. With InstallationID unset, the installation is taken from
+// each webhook delivery.
+type GitHubAppConfig struct {
+	AppID          int64  `yaml:"app_id"`
+	PrivateKeyPath string `yaml:"private_key_path"`
+	InstallationID int64  `yaml:"installation_id"`
 }
 
 type FunnelConfig struct {
@@ -28,12 +116,32 @@ type DaemonConfig struct {
 	LogFile string `yaml:"log_file"`
 }
 
+// RunsConfig limits the run history. Zero values get defaults; -1 disables
+// the limit.
+type RunsConfig struct {
+	MaxLogMB      int `yaml:"max_log_mb"`
+	RetentionDays int `yaml:"retention_days"`
+	MaxRuns       int `yaml:"max_runs"`
+}
+
 type Config struct {
-	WebhookSecret string                    `yaml:"webhook_secret"`
-	Port          int                       `yaml:"port"`
-	Funnel        FunnelConfig              `yaml:"funnel"`
-	Daemon        DaemonConfig              `yaml:"daemon"`
-	Workflows     map[string]WorkflowConfig `yaml:"workflows"`
+	WebhookSecret     string                    `yaml:"webhook_secret"`
+	Port              int                       `yaml:"port"`
+	StateDir          string                    `yaml:"state_dir"`
+	MaxConcurrentJobs int                       `yaml:"max_concurrent_jobs"`
+	DeliveryHistory   int                       `yaml:"delivery_history"`
+	Repos             []string                  `yaml:"repos"`
+	IgnoreBots        bool                      `yaml:"ignore_bots"`
+	IgnoreAuthors     []string                  `yaml:"ignore_authors"`
+	GitHub            GitHubConfig              `yaml:"github"`
+	GitHubApp         GitHubAppConfig           `yaml:"github_app"`
+	GitLab            GitLabConfig              `yaml:"gitlab"`
+	Gitea             GiteaConfig               `yaml:"gitea"`
+	Sources           map[string]SourceConfig   `yaml:"sources"`
+	Funnel            FunnelConfig              `yaml:"funnel"`
+	Daemon            DaemonConfig              `yaml:"daemon"`
+	Runs              RunsConfig                `yaml:"runs"`
+	Workflows         map[string]WorkflowConfig `yaml:"workflows"`
 }
 
 func DefaultPath() string {
@@ -49,6 +157,15 @@ func ExpandTilde(path string) string {
 	return path
 }
 
+// StatePath joins elem onto the expanded state directory. It returns "" when
+// no state directory is configured, which callers treat as "keep in memory".
+func StatePath(cfg *Config, elem ...string) string {
+	if cfg.StateDir == "" {
+		return ""
+	}
+	return filepath.Join(append([]string{ExpandTilde(cfg.StateDir)}, elem...)...)
+}
+
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(ExpandTilde(path))
 	if err != nil {
@@ -73,23 +190,226 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.Port == 0 {
 		cfg.Port = 8443
 	}
+	if cfg.StateDir == "" {
+		cfg.StateDir = "~/.hookrunner"
+	}
+	if cfg.MaxConcurrentJobs == 0 {
+		cfg.MaxConcurrentJobs = 2
+	}
+	if cfg.DeliveryHistory == 0 {
+		cfg.DeliveryHistory = 1000
+	}
+	if cfg.Runs.MaxLogMB == 0 {
+		cfg.Runs.MaxLogMB = 10
+	}
+	if cfg.Runs.RetentionDays == 0 {
+		cfg.Runs.RetentionDays = 30
+	}
+	if cfg.Runs.MaxRuns == 0 {
+		cfg.Runs.MaxRuns = 500
+	}
+	if cfg.GitHub.APIURL == "" {
+		cfg.GitHub.APIURL = "https://api.github.com"
+	}
 	if cfg.Daemon.PIDFile == "" {
 		cfg.Daemon.PIDFile = "~/.hookrunner/hookrunner.pid"
 	}
 	if cfg.Daemon.LogFile == "" {
 		cfg.Daemon.LogFile = "~/.hookrunner/hookrunner.log"
 	}
+	for name, src := range cfg.Sources {
+		if src.Header == "" {
+			switch src.Auth {
+			case "hmac":
+				src.Header = "X-Hub-Signature-256"
+			case "token":
+				src.Header = "Authorization"
+			}
+		}
+		cfg.Sources[name] = src
+	}
 	for name, wf := range cfg.Workflows {
 		if wf.Timeout == 0 {
 			wf.Timeout = 300
 		}
-		if len(wf.Events) == 0 {
-			wf.Events = []string{"issue_comment", "pull_request_review_comment", "pull_request_review"}
+		if wf.Report.TailLines == 0 {
+			wf.Report.TailLines = 20
+		}
+		if len(wf.Events) == 0 && wf.Source == "" {
+			wf.Events = DefaultEvents
 		}
 		cfg.Workflows[name] = wf
 	}
 }
 
+// AuthorAssociations are the values GitHub sends in author_association.
+var AuthorAssociations = []string{
+	"OWNER", "MEMBER", "COLLABORATOR", "CONTRIBUTOR", "FIRST_TIME_CONTRIBUTOR", "FIRST_TIMER", "MANNEQUIN", "NONE",
+}
+
+// DefaultEvents are the events a workflow listens to when it lists none.
+var DefaultEvents = []string{"issue_comment", "pull_request_review_comment", "pull_request_review"}
+
+// EventActions lists the actions GitHub sends for each supported event that
+// has them. A workflow's actions must each be valid for one of its events.
+var EventActions = map[string][]string{
+	"issue_comment":               {"created", "edited", "deleted"},
+	"pull_request_review_comment": {"created", "edited", "deleted"},
+	"pull_request_review":         {"submitted", "edited", "dismissed"},
+	"discussion_comment":          {"created", "edited", "deleted"},
+	"discussion": {"created", "edited", "deleted", "pinned", "unpinned", "locked", "unlocked", "transferred",
+		"category_changed", "answered", "unanswered", "labeled", "unlabeled", "closed", "reopened"},
+	"pull_request": {"opened", "edited", "closed", "reopened", "synchronize", "assigned", "unassigned",
+		"labeled", "unlabeled", "locked", "unlocked", "converted_to_draft", "ready_for_review",
+		"review_requested", "review_request_removed", "auto_merge_enabled", "auto_merge_disabled",
+		"milestoned", "demilestoned", "enqueued", "dequeued"},
+	"issues": {"opened", "edited", "deleted", "closed", "reopened", "assigned", "unassigned", "labeled",
+		"unlabeled", "locked", "unlocked", "pinned", "unpinned", "transferred", "milestoned", "demilestoned",
+		"typed", "untyped"},
+	"workflow_run":      {"requested", "in_progress", "completed"},
+	"check_run":         {"created", "completed", "rerequested", "requested_action"},
+	"check_suite":       {"requested", "rerequested", "completed"},
+	"release":           {"published", "unpublished", "created", "edited", "deleted", "prereleased", "released"},
+	"deployment":        {"created"},
+	"deployment_status": {"created"},
+}
+
+func validAction(events []string, action string) bool {
+	for _, e := range events {
+		for _, a := range EventActions[e] {
+			if a == action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	commandNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	flagNamePattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+)
+
+func validateCommand(wf WorkflowConfig) error {
+	if wf.CommandName == "" {
+		if len(wf.CommandFlags) > 0 || wf.CommandArgs != (CommandArgsConfig{}) {
+			return fmt.Errorf("command_flags and command_args require command_name")
+		}
+		return nil
+	}
+	if !commandNamePattern.MatchString(wf.CommandName) {
+		return fmt.Errorf("command_name %q must be a single word", wf.CommandName)
+	}
+	if wf.CommandArgs.Min < 0 || wf.CommandArgs.Max < 0 {
+		return fmt.Errorf("command_args: min and max must not be negative")
+	}
+	if wf.CommandArgs.Max > 0 && wf.CommandArgs.Min > wf.CommandArgs.Max {
+		return fmt.Errorf("command_args: min must not exceed max")
+	}
+	for name, f := range wf.CommandFlags {
+		if !flagNamePattern.MatchString(name) {
+			return fmt.Errorf("command flag %q: name must start with a letter and contain only letters, digits, - and _", name)
+		}
+		switch f.Type {
+		case "", "string":
+		case "int":
+			for _, v := range append([]string{f.Default}, f.Values...) {
+				if _, err := strconv.Atoi(v); v != "" && err != nil {
+					return fmt.Errorf("command flag %q: %q is not an int", name, v)
+				}
+			}
+		case "bool":
+			if len(f.Values) > 0 {
+				return fmt.Errorf("command flag %q: bool flags cannot have values", name)
+			}
+			if _, err := strconv.ParseBool(f.Default); f.Default != "" && err != nil {
+				return fmt.Errorf("command flag %q: default %q is not a bool", name, f.Default)
+			}
+		default:
+			return fmt.Errorf("command flag %q: unknown type %q", name, f.Type)
+		}
+		if f.Default != "" && len(f.Values) > 0 {
+			valid := false
+			for _, v := range f.Values {
+				if v == f.Default {
+					valid = true
+				}
+			}
+			if !valid {
+				return fmt.Errorf("command flag %q: default %q is not one of its values", name, f.Default)
+			}
+		}
+	}
+	return nil
+}
+
+// fieldNamePattern keeps source field names usable as {{.Fields.name}} and
+// in HR_FIELD_NAME.
+var fieldNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+func validateSource(src SourceConfig) error {
+	if !strings.HasPrefix(src.Path, "/") || src.Path == "/webhook" || strings.HasPrefix(src.Path, "/webhook/") || src.Path == "/healthz" {
+		return fmt.Errorf("path must start with / and not be /healthz, /webhook or under /webhook/")
+	}
+	// Paths are registered on an http.ServeMux, which panics on patterns it
+	// cannot parse or that overlap the catch-all /.
+	if src.Path == "/" || strings.ContainsAny(src.Path, "{} \t") || path.Clean(src.Path) != strings.TrimSuffix(src.Path, "/") {
+		return fmt.Errorf("path must be a clean URL path other than / without {, } or spaces")
+	}
+	switch src.Auth {
+	case "hmac", "token":
+		if src.Secret == "" {
+			return fmt.Errorf("auth %s requires secret", src.Auth)
+		}
+	case "none":
+	default:
+		return fmt.Errorf("auth must be hmac, token or none")
+	}
+	if src.EventHeader != "" && src.EventPath != "" {
+		return fmt.Errorf("set only one of event_header and event_path")
+	}
+	if src.EventPath != "" {
+		if _, err := jsonpath.Parse(src.EventPath); err != nil {
+			return fmt.Errorf("event_path: %w", err)
+		}
+	}
+	for name, p := range src.Fields {
+		if !fieldNamePattern.MatchString(name) {
+			return fmt.Errorf("field %q: name must start with a letter and contain only letters, digits and _", name)
+		}
+		if _, err := jsonpath.Parse(p); err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validateSourceWorkflow checks a workflow that runs on deliveries from a
+// source rather than from GitHub.
+func validateSourceWorkflow(cfg *Config, wf WorkflowConfig) error {
+	src, ok := cfg.Sources[wf.Source]
+	if !ok {
+		return fmt.Errorf("unknown source %q", wf.Source)
+	}
+	for name, pattern := range wf.Fields {
+		if _, ok := src.Fields[name]; !ok {
+			return fmt.Errorf("field %q is not defined by source %q", name, wf.Source)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+	}
+	filters := len(wf.Repos) + len(wf.Actions) + len(wf.Authors) + len(wf.AuthorAssociation) + len(wf.Teams) +
+		len(wf.IgnoreAuthors) + len(wf.Branches) + len(wf.Paths) + len(wf.Labels)
+	if filters > 0 || wf.IgnoreBots != nil || wf.CommandName != "" {
+		return fmt.Errorf("GitHub event filters cannot be used with source")
+	}
+	if wf.Report.Reactions || wf.Report.Check || wf.Report.Comment || wf.Report.Update {
+		return fmt.Errorf("report cannot be used with source")
+	}
+	return nil
+}
+
 func ValidateConfig(cfg *Config) error {
 	if cfg.WebhookSecret == "" {
 		return fmt.Errorf("webhook_secret is required")
@@ -101,25 +421,125 @@ func ValidateConfig(cfg *Config) error {
 	if cfg.Funnel.Enabled && !validFunnelPorts[cfg.Port] {
 		return fmt.Errorf("port must be 443, 8443, or 10000 when funnel is enabled")
 	}
+	if cfg.MaxConcurrentJobs < 0 {
+		return fmt.Errorf("max_concurrent_jobs must not be negative")
+	}
+	if cfg.DeliveryHistory < 0 {
+		return fmt.Errorf("delivery_history must not be negative")
+	}
+	if cfg.Runs.MaxLogMB < -1 || cfg.Runs.RetentionDays < -1 || cfg.Runs.MaxRuns < -1 {
+		return fmt.Errorf("runs: max_log_mb, retention_days and max_runs must be positive, 0 for the default or -1 for no limit")
+	}
+	for _, p := range cfg.Repos {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("repos: invalid glob %q", p)
+		}
+	}
+	app := cfg.GitHubApp
+	if (app.AppID != 0 || app.PrivateKeyPath != "" || app.InstallationID != 0) && (app.AppID <= 0 || app.PrivateKeyPath == "") {
+		return fmt.Errorf("github_app: app_id and private_key_path are required")
+	}
+	if app.InstallationID < 0 {
+		return fmt.Errorf("github_app: installation_id must not be negative")
+	}
+	hasAuth := cfg.GitHub.Token != "" || app.AppID != 0
+	paths := map[string]string{}
+	for name, src := range cfg.Sources {
+		if err := validateSource(src); err != nil {
+			return fmt.Errorf("source %q: %w", name, err)
+		}
+		if other, ok := paths[src.Path]; ok {
+			return fmt.Errorf("sources %q and %q have the same path %s", other, name, src.Path)
+		}
+		paths[src.Path] = name
+	}
 	for name, wf := range cfg.Workflows {
-		if wf.Trigger == "" {
+		if wf.Source != "" {
+			if err := validateSourceWorkflow(cfg, wf); err != nil {
+				return fmt.Errorf("workflow %q: %w", name, err)
+			}
+		} else if wf.Trigger == "" {
 			return fmt.Errorf("workflow %q: trigger is required", name)
+		} else if len(wf.Fields) > 0 {
+			return fmt.Errorf("workflow %q: fields requires source", name)
 		}
 		if wf.Command == "" {
 			return fmt.Errorf("workflow %q: command is required", name)
 		}
+		if err := validateCommand(wf); err != nil {
+			return fmt.Errorf("workflow %q: %w", name, err)
+		}
+		events := wf.Events
+		if len(events) == 0 {
+			events = DefaultEvents
+		}
+		for _, a := range wf.Actions {
+			if !validAction(events, a) {
+				return fmt.Errorf("workflow %q: action %q is not sent for any of its events", name, a)
+			}
+		}
+		for _, a := range wf.AuthorAssociation {
+			valid := false
+			for _, known := range AuthorAssociations {
+				if strings.EqualFold(a, known) {
+					valid = true
+				}
+			}
+			if !valid {
+				return fmt.Errorf("workflow %q: unknown author_association %q", name, a)
+			}
+		}
+		for _, t := range wf.Teams {
+			if t == "" || strings.Count(t, "/") > 1 {
+				return fmt.Errorf("workflow %q: team %q must be a slug or org/slug", name, t)
+			}
+		}
+		if len(wf.Teams) > 0 && !hasAuth {
+			return fmt.Errorf("workflow %q: teams requires github.token or github_app", name)
+		}
+		for _, p := range append(append(append([]string(nil), wf.Repos...), wf.Branches...), wf.Paths...) {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("workflow %q: invalid glob %q", name, p)
+			}
+		}
+		if wf.Concurrency < 0 {
+			return fmt.Errorf("workflow %q: concurrency must not be negative", name)
+		}
+		if wf.CancelInProgress && wf.ConcurrencyGroup == "" {
+			return fmt.Errorf("workflow %q: cancel_in_progress requires concurrency_group", name)
+		}
+		if _, err := template.New("group").Parse(wf.ConcurrencyGroup); err != nil {
+			return fmt.Errorf("workflow %q: invalid concurrency_group: %w", name, err)
+		}
+		if wf.Report.TailLines < 0 {
+			return fmt.Errorf("workflow %q: report.tail_lines must not be negative", name)
+		}
+		if wf.Report.Comment && !hasAuth {
+			return fmt.Errorf("workflow %q: report.comment requires github.token or github_app", name)
+		}
+		if wf.Report.Reactions && !hasAuth {
+			return fmt.Errorf("workflow %q: report.reactions requires github.token or github_app", name)
+		}
+		if wf.Report.Check && !hasAuth {
+			return fmt.Errorf("workflow %q: report.check requires github.token or github_app", name)
+		}
 	}
 	return nil
 }
 
 const DefaultConfigYAML = `webhook_secret: "changeme"
 port: 8443
+max_concurrent_jobs: 2
 funnel:
   enabled: true
   url: ""
 daemon:
   pid_file: "~/.hookrunner/hookrunner.pid"
   log_file: "~/.hookrunner/hookrunner.log"
+runs:
+  max_log_mb: 10
+  retention_days: 30
+  max_runs: 500
 workflows:
   claude-review:
     trigger: '/cc'
@@ -60,6 +60,32 @@ func TestValidateConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("invalid concurrency_group", func(t *testing.T) {
+		cfg := &Config{
+			WebhookSecret: "s",
+			Port:          8080,
+			Workflows: map[string]WorkflowConfig{
+				"test": {Trigger: "foo", Command: "echo", ConcurrencyGroup: "{{.PRNumber"},
+			},
+		}
+		if err := ValidateConfig(cfg); err == nil {
+			t.Error("expected error for unterminated concurrency_group template")
+		}
+	})
+
+	t.Run("negative tail_lines", func(t *testing.T) {
+		cfg := &Config{
+			WebhookSecret: "s",
+			Port:          8080,
+			Workflows: map[string]WorkflowConfig{
+				"test": {Trigger: "foo", Command: "echo", Report: ReportConfig{TailLines: -1}},
+			},
+		}
+		if err := ValidateConfig(cfg); err == nil {
+			t.Error("expected error for negative tail_lines")
+		}
+	})
+
 	t.Run("workflow missing trigger", func(t *testing.T) {
 		cfg := &Config{
 			WebhookSecret: "s",
@@ -73,6 +99,148 @@ func TestValidateConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("actions", func(t *testing.T) {
+		cfg := &Config{
+			WebhookSecret: "s",
+			Port:          8080,
+			Workflows: map[string]WorkflowConfig{
+				"test": {Trigger: "foo", Command: "bar", Events: []string{"issue_comment", "pull_request"}, Actions: []string{"edited", "closed"}},
+			},
+		}
+		if err := ValidateConfig(cfg); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		cfg.Workflows["test"] = WorkflowConfig{Trigger: "foo", Command: "bar", Actions: []string{"closed"}}
+		if err := ValidateConfig(cfg); err == nil {
+			t.Error("expected error for action not sent for the default events")
+		}
+		cfg.Workflows["test"] = WorkflowConfig{Trigger: "foo", Command: "bar", Events: []string{"push"}, Actions: []string{"created"}}
+		if err := ValidateConfig(cfg); err == nil {
+			t.Error("expected error for actions on an event without actions")
+		}
+	})
+
+	t.Run("runs limits", func(t *testing.T) {
+		cfg := &Config{WebhookSecret: "s", Port: 8080, Runs: RunsConfig{MaxLogMB: -1, RetentionDays: -1, MaxRuns: 100}}
+		ApplyDefaults(cfg)
+		if cfg.Runs.MaxLogMB != -1 || cfg.Runs.RetentionDays != -1 {
+			t.Errorf("-1 replaced by defaults: %+v", cfg.Runs)
+		}
+		if err := ValidateConfig(cfg); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		cfg.Runs.MaxRuns = -2
+		if err := ValidateConfig(cfg); err == nil {
+			t.Error("expected error for max_runs below -1")
+		}
+	})
+
+	t.Run("slash command", func(t *testing.T) {
+		cfg := &Config{WebhookSecret: "s", Port: 8080}
+		valid := WorkflowConfig{
+			Trigger:     "/cc",
+			Command:     "bar",
+			CommandName: "review",
+			CommandArgs: CommandArgsConfig{Min: 1, Max: 2},
+			CommandFlags: map[string]CommandFlag{
+				"model":   {Values: []string{"opus", "sonnet"}, Default: "sonnet"},
+				"depth":   {Type: "int", Default: "2"},
+				"dry-run": {Type: "bool"},
+			},
+		}
+		cfg.Workflows = map[string]WorkflowConfig{"test": valid}
+		if err := ValidateConfig(cfg); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		invalid := map[string]func(wf *WorkflowConfig){
+			"flags without command_name": func(wf *WorkflowConfig) { wf.CommandName = "" },
+			"command_name with spaces":   func(wf *WorkflowConfig) { wf.CommandName = "re view" },
+			"min above max":              func(wf *WorkflowConfig) { wf.CommandArgs.Min = 3 },
+			"unknown type":               func(wf *WorkflowConfig) { wf.CommandFlags["x"] = CommandFlag{Type: "float"} },
+			"bad flag name":              func(wf *WorkflowConfig) { wf.CommandFlags["1st"] = CommandFlag{} },
+			"default not in values": func(wf *WorkflowConfig) {
+				wf.CommandFlags["model"] = CommandFlag{Values: []string{"opus"}, Default: "gpt"}
+			},
+			"int default": func(wf *WorkflowConfig) { wf.CommandFlags["depth"] = CommandFlag{Type: "int", Default: "deep"} },
+		}
+		for name, mutate := range invalid {
+			wf := valid
+			wf.CommandFlags = map[string]CommandFlag{}
+			for k, v := range valid.CommandFlags {
+				wf.CommandFlags[k] = v
+			}
+			mutate(&wf)
+			cfg.Workflows = map[string]WorkflowConfig{"test": wf}
+			if err := ValidateConfig(cfg); err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		cfg := &Config{
+			WebhookSecret: "s",
+			Port:          8080,
+			Sources: map[string]SourceConfig{
+				"alerts": {Path: "/hooks/alerts", Auth: "token", Secret: "t", EventPath: "$.status",
+					Fields: map[string]string{"severity": "$.alert.severity"}},
+			},
+			Workflows: map[string]WorkflowConfig{
+				"page": {Source: "alerts", Fields: map[string]string{"severity": "critical"}, Command: "bar"},
+			},
+		}
+		if err := ValidateConfig(cfg); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		invalid := map[string]Config{
+			"unknown source": {Workflows: map[string]WorkflowConfig{"page": {Source: "nope", Command: "bar"}}},
+			"undefined field": {Workflows: map[string]WorkflowConfig{
+				"page": {Source: "alerts", Fields: map[string]string{"name": "x"}, Command: "bar"}}},
+			"github filter": {Workflows: map[string]WorkflowConfig{
+				"page": {Source: "alerts", Authors: []string{"octocat"}, Command: "bar"}}},
+			"fields without source": {Workflows: map[string]WorkflowConfig{
+				"review": {Trigger: "foo", Fields: map[string]string{"severity": "x"}, Command: "bar"}}},
+			"bad path":  {Sources: map[string]SourceConfig{"alerts": {Path: "/webhook", Auth: "none"}}},
+			"root path": {Sources: map[string]SourceConfig{"alerts": {Path: "/", Auth: "none"}}},
+			"wildcard":  {Sources: map[string]SourceConfig{"alerts": {Path: "/hooks/{name}", Auth: "none"}}},
+			"space":     {Sources: map[string]SourceConfig{"alerts": {Path: "/hooks /alerts", Auth: "none"}}},
+			"unclean":   {Sources: map[string]SourceConfig{"alerts": {Path: "/hooks//alerts", Auth: "none"}}},
+			"no secret": {Sources: map[string]SourceConfig{"alerts": {Path: "/a", Auth: "hmac"}}},
+			"bad auth":  {Sources: map[string]SourceConfig{"alerts": {Path: "/a", Auth: "basic"}}},
+			"bad json path": {Sources: map[string]SourceConfig{
+				"alerts": {Path: "/a", Auth: "none", Fields: map[string]string{"x": "alert.x"}}}},
+			"same path": {Sources: map[string]SourceConfig{
+				"a": {Path: "/a", Auth: "none"}, "b": {Path: "/a", Auth: "none"}}},
+		}
+		for name, c := range invalid {
+			c.WebhookSecret, c.Port = "s", 8080
+			if c.Sources == nil {
+				c.Sources = cfg.Sources
+			}
+			if err := ValidateConfig(&c); err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+	})
+
+	t.Run("github app", func(t *testing.T) {
+		cfg := &Config{
+			WebhookSecret: "s",
+			Port:          8080,
+			GitHubApp:     GitHubAppConfig{AppID: 123},
+		}
+		if err := ValidateConfig(cfg); err == nil {
+			t.Error("expected error for github_app without private_key_path")
+		}
+		cfg.GitHubApp.PrivateKeyPath = "/key.pem"
+		cfg.Workflows = map[string]WorkflowConfig{
+			"test": {Trigger: "foo", Command: "bar", Report: ReportConfig{Comment: true}},
+		}
+		if err := ValidateConfig(cfg); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
 	t.Run("valid config", func(t *testing.T) {
 		cfg := &Config{
 			WebhookSecret: "s",
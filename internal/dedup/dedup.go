@@ -0,0 +1,113 @@
+package dedup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store remembers the most recent delivery IDs. It keeps at most max IDs in
+// memory and compacts the backing file once it grows past twice that.
+type Store struct {
+	path string
+	max  int
+
+	mu        sync.Mutex
+	ids       []string
+	seen      map[string]bool
+	fileLines int
+}
+
+// Open loads the store at path. An empty path gives a store that only lives
+// in memory.
+func Open(path string, max int) (*Store, error) {
+	s := &Store{path: path, max: max, seen: make(map[string]bool)}
+	if path == "" {
+		return s, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening delivery store: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		id := strings.TrimSpace(scanner.Text())
+		if id == "" {
+			continue
+		}
+		s.fileLines++
+		s.remember(id)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading delivery store: %w", err)
+	}
+	return s, nil
+}
+
+// Record marks id as processed and reports whether it had been seen before.
+func (s *Store) Record(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen[id] {
+		return true, nil
+	}
+	s.remember(id)
+
+	if s.path == "" {
+		return false, nil
+	}
+	if s.fileLines >= 2*s.max {
+		return false, s.compact()
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return false, fmt.Errorf("creating delivery store dir: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return false, fmt.Errorf("opening delivery store: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(id + "\n"); err != nil {
+		return false, fmt.Errorf("writing delivery store: %w", err)
+	}
+	s.fileLines++
+	return false, nil
+}
+
+func (s *Store) remember(id string) {
+	if s.seen[id] {
+		return
+	}
+	s.ids = append(s.ids, id)
+	s.seen[id] = true
+	if s.max > 0 && len(s.ids) > s.max {
+		delete(s.seen, s.ids[0])
+		s.ids = s.ids[1:]
+	}
+}
+
+func (s *Store) compact() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("creating delivery store dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	data := strings.Join(s.ids, "\n") + "\n"
+	if err := os.WriteFile(tmp, []byte(data), 0600); err != nil {
+		return fmt.Errorf("compacting delivery store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("compacting delivery store: %w", err)
+	}
+	s.fileLines = len(s.ids)
+	return nil
+}
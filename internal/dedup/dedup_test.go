@@ -0,0 +1,72 @@
+package dedup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	s, err := Open("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dup, _ := s.Record("a"); dup {
+		t.Error("first delivery reported as duplicate")
+	}
+	if dup, _ := s.Record("a"); !dup {
+		t.Error("second delivery not reported as duplicate")
+	}
+	if dup, _ := s.Record("b"); dup {
+		t.Error("different delivery reported as duplicate")
+	}
+}
+
+func TestBounded(t *testing.T) {
+	s, _ := Open("", 3)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		s.Record(id)
+	}
+	if dup, _ := s.Record("a"); dup {
+		t.Error("expected oldest ID to be evicted")
+	}
+	if dup, _ := s.Record("d"); !dup {
+		t.Error("expected recent ID to be remembered")
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deliveries")
+
+	s, err := Open(path, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 12; i++ {
+		if _, err := s.Record(fmt.Sprintf("id-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines > 10 {
+		t.Errorf("store file has %d lines, expected compaction to keep it at most 10", lines)
+	}
+
+	reopened, err := Open(path, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dup, _ := reopened.Record("id-11"); !dup {
+		t.Error("expected recorded ID to survive reopen")
+	}
+	if dup, _ := reopened.Record("id-0"); dup {
+		t.Error("expected old ID to have been evicted")
+	}
+}
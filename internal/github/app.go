@@ -0,0 +1,139 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry a cached installation token is
+// replaced, so a token never expires in the middle of a request.
+const tokenRefreshMargin = 5 * time.Minute
+
+// App authenticates as a GitHub App and hands out installation access tokens,
+// caching each until shortly before it expires.
+type App struct {
+	id     int64
+	key    *rsa.PrivateKey
+	tokens *Client // unauthenticated client for the access token endpoint
+	now    func() time.Time
+	mu     sync.Mutex
+	cache  map[int64]installationToken
+}
+
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewApp(baseURL string, appID int64, key *rsa.PrivateKey) *App {
+	return &App{
+		id:     appID,
+		key:    key,
+		tokens: NewClient(baseURL, ""),
+		now:    time.Now,
+		cache:  make(map[int64]installationToken),
+	}
+}
+
+// LoadPrivateKey reads a PEM encoded RSA key as downloaded from the GitHub App
+// settings page.
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: parsing private key: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an RSA private key", path)
+	}
+	return key, nil
+}
+
+// JWT returns a token identifying the app itself, valid for 9 minutes. The
+// issue time is backdated to allow for clock drift.
+func (a *App) JWT() (string, error) {
+	now := a.now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.id, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("signing JWT: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// InstallationToken returns an access token for the installation, minting a
+// new one when the cached token is missing or about to expire.
+func (a *App) InstallationToken(ctx context.Context, installationID int64) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if tok, ok := a.cache[installationID]; ok && a.now().Add(tokenRefreshMargin).Before(tok.ExpiresAt) {
+		return tok.Token, nil
+	}
+
+	jwt, err := a.JWT()
+	if err != nil {
+		return "", err
+	}
+	var tok installationToken
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", installationID)
+	if err := a.tokens.request(ctx, http.MethodPost, path, "Bearer "+jwt, nil, &tok); err != nil {
+		return "", fmt.Errorf("creating installation token: %w", err)
+	}
+	if tok.Token == "" {
+		return "", errors.New("creating installation token: empty token in response")
+	}
+	a.cache[installationID] = tok
+	return tok.Token, nil
+}
+
+type installationKey struct{}
+
+// WithInstallation returns a context that makes app-authenticated clients act
+// as the given installation.
+func WithInstallation(ctx context.Context, installationID int64) context.Context {
+	if installationID == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, installationKey{}, installationID)
+}
+
+func installationFrom(ctx context.Context) int64 {
+	id, _ := ctx.Value(installationKey{}).(int64)
+	return id
+}
@@ -0,0 +1,191 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTokenEndpoint issues numbered installation tokens after checking the
+// app JWT, and records the Authorization header of every API request.
+type fakeTokenEndpoint struct {
+	key *rsa.PublicKey
+
+	mu       sync.Mutex
+	minted   []string // installation IDs tokens were minted for
+	rejected []error
+	apiAuth  []string
+	now      time.Time
+}
+
+func (f *fakeTokenEndpoint) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /app/installations/{id}/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if err := f.verifyJWT(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")); err != nil {
+			f.rejected = append(f.rejected, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f.minted = append(f.minted, r.PathValue("id"))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(installationToken{
+			Token:     fmt.Sprintf("ghs_%s_%d", r.PathValue("id"), len(f.minted)),
+			ExpiresAt: f.now.Add(time.Hour),
+		})
+	})
+	mux.HandleFunc("POST /repos/org/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.apiAuth = append(f.apiAuth, r.Header.Get("Authorization"))
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Comment{ID: 1})
+	})
+	return mux
+}
+
+func (f *fakeTokenEndpoint) verifyJWT(jwt string) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%d parts", len(parts))
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(f.key, crypto.SHA256, sum[:], sig); err != nil {
+		return err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	var claims struct {
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Iss string `json:"iss"`
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+	if claims.Iss != "123" {
+		return fmt.Errorf("iss = %q", claims.Iss)
+	}
+	if claims.Exp-claims.Iat > 600 || claims.Iat > f.now.Unix() || claims.Exp <= f.now.Unix() {
+		return fmt.Errorf("bad validity window %d..%d at %d", claims.Iat, claims.Exp, f.now.Unix())
+	}
+	return nil
+}
+
+func TestAppClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	fake := &fakeTokenEndpoint{key: &key.PublicKey, now: now}
+	srv := httptest.NewServer(fake.handler())
+	defer srv.Close()
+
+	app := NewApp(srv.URL, 123, key)
+	app.now = func() time.Time { return now }
+	c := NewAppClient(srv.URL, app, 7)
+	comment := func(ctx context.Context) {
+		t.Helper()
+		if _, err := c.CreateIssueComment(ctx, "org/repo", 1, "hi"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	comment(context.Background())
+	comment(context.Background())
+	comment(WithInstallation(context.Background(), 9))
+
+	// Within the refresh margin of expiry the token is replaced.
+	now = now.Add(56 * time.Minute)
+	fake.now = now
+	comment(context.Background())
+
+	if len(fake.rejected) > 0 {
+		t.Fatalf("token endpoint rejected JWTs: %v", fake.rejected)
+	}
+	wantMinted := []string{"7", "9", "7"}
+	if fmt.Sprint(fake.minted) != fmt.Sprint(wantMinted) {
+		t.Errorf("tokens minted for %v, want %v", fake.minted, wantMinted)
+	}
+	wantAuth := []string{"Bearer ghs_7_1", "Bearer ghs_7_1", "Bearer ghs_9_2", "Bearer ghs_7_3"}
+	if fmt.Sprint(fake.apiAuth) != fmt.Sprint(wantAuth) {
+		t.Errorf("API requests authorized with %v, want %v", fake.apiAuth, wantAuth)
+	}
+
+	t.Run("no installation", func(t *testing.T) {
+		c := NewAppClient(srv.URL, app, 0)
+		if _, err := c.CreateIssueComment(context.Background(), "org/repo", 1, "hi"); err == nil {
+			t.Error("expected error without an installation ID")
+		}
+	})
+
+	t.Run("token endpoint error", func(t *testing.T) {
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bad := NewApp(srv.URL, 123, other)
+		if _, err := bad.InstallationToken(context.Background(), 7); err == nil {
+			t.Error("expected error for a JWT signed with the wrong key")
+		}
+	})
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"pkcs1.pem": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		"pkcs8.pem": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		"bad.pem":   []byte("not a key"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"pkcs1.pem", "pkcs8.pem"} {
+		got, err := LoadPrivateKey(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !got.Equal(key) {
+			t.Errorf("%s: loaded a different key", name)
+		}
+	}
+	if _, err := LoadPrivateKey(filepath.Join(dir, "bad.pem")); err == nil {
+		t.Error("expected error for non-PEM file")
+	}
+}
@@ -0,0 +1,272 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client is a minimal GitHub REST API client covering the calls hookrunner
+// makes back to GitHub.
+type Client struct {
+	baseURL        string
+	token          string
+	app            *App
+	installationID int64
+	http           *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NewAppClient returns a client authenticated as an installation of app.
+// The installation comes from the request context (see WithInstallation),
+// falling back to installationID when the context has none.
+func NewAppClient(baseURL string, app *App, installationID int64) *Client {
+	c := NewClient(baseURL, "")
+	c.app = app
+	c.installationID = installationID
+	return c
+}
+
+type Comment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+func (c *Client) CreateIssueComment(ctx context.Context, repo string, number int, body string) (*Comment, error) {
+	var comment Comment
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number)
+	if err := c.do(ctx, http.MethodPost, path, map[string]string{"body": body}, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (c *Client) UpdateIssueComment(ctx context.Context, repo string, id int64, body string) (*Comment, error) {
+	var comment Comment
+	path := fmt.Sprintf("/repos/%s/issues/comments/%d", repo, id)
+	if err := c.do(ctx, http.MethodPatch, path, map[string]string{"body": body}, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (c *Client) ListIssueComments(ctx context.Context, repo string, number int) ([]Comment, error) {
+	var all []Comment
+	for page := 1; ; page++ {
+		var comments []Comment
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", repo, number, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+			return nil, err
+		}
+		all = append(all, comments...)
+		if len(comments) < 100 {
+			return all, nil
+		}
+	}
+}
+
+// ReactionSubject identifies something reactions can be added to: an issue or
+// PR (by number), an issue comment or a PR review comment (by comment ID).
+type ReactionSubject struct {
+	Repo string
+	Kind string // "issue", "issue_comment" or "pull_request_review_comment"
+	ID   int64
+}
+
+func (s ReactionSubject) path() (string, error) {
+	switch s.Kind {
+	case "issue":
+		return fmt.Sprintf("/repos/%s/issues/%d/reactions", s.Repo, s.ID), nil
+	case "issue_comment":
+		return fmt.Sprintf("/repos/%s/issues/comments/%d/reactions", s.Repo, s.ID), nil
+	case "pull_request_review_comment":
+		return fmt.Sprintf("/repos/%s/pulls/comments/%d/reactions", s.Repo, s.ID), nil
+	default:
+		return "", fmt.Errorf("github: cannot react to %q", s.Kind)
+	}
+}
+
+type Reaction struct {
+	ID      int64  `json:"id"`
+	Content string `json:"content"`
+}
+
+// AddReaction adds a reaction such as "eyes", "rocket" or "confused". Adding
+// a reaction that already exists returns the existing one.
+func (c *Client) AddReaction(ctx context.Context, subject ReactionSubject, content string) (*Reaction, error) {
+	path, err := subject.path()
+	if err != nil {
+		return nil, err
+	}
+	var reaction Reaction
+	if err := c.do(ctx, http.MethodPost, path, map[string]string{"content": content}, &reaction); err != nil {
+		return nil, err
+	}
+	return &reaction, nil
+}
+
+func (c *Client) DeleteReaction(ctx context.Context, subject ReactionSubject, id int64) error {
+	path, err := subject.path()
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("%s/%d", path, id), nil, nil)
+}
+
+type CheckRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
+
+type CheckRun struct {
+	ID         int64           `json:"id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	HeadSHA    string          `json:"head_sha,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	Conclusion string          `json:"conclusion,omitempty"`
+	Output     *CheckRunOutput `json:"output,omitempty"`
+}
+
+// CreateCheckRun creates a check run. GitHub only allows this for GitHub
+// Apps; other tokens get a 403.
+func (c *Client) CreateCheckRun(ctx context.Context, repo string, run CheckRun) (*CheckRun, error) {
+	var created CheckRun
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/check-runs", repo), run, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) UpdateCheckRun(ctx context.Context, repo string, id int64, run CheckRun) (*CheckRun, error) {
+	var updated CheckRun
+	if err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/check-runs/%d", repo, id), run, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+type Status struct {
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	Description string `json:"description,omitempty"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// CreateStatus sets a commit status. State is one of "pending", "success",
+// "failure" or "error".
+func (c *Client) CreateStatus(ctx context.Context, repo, sha string, status Status) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/statuses/%s", repo, sha), status, nil)
+}
+
+// IsTeamMember reports whether user is an active member of the team with the
+// given slug in org. Pending invitations do not count.
+func (c *Client) IsTeamMember(ctx context.Context, org, team, user string) (bool, error) {
+	var membership struct {
+		State string `json:"state"`
+	}
+	path := fmt.Sprintf("/orgs/%s/teams/%s/memberships/%s", org, team, user)
+	err := c.do(ctx, http.MethodGet, path, nil, &membership)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return membership.State == "active", nil
+}
+
+// Error is returned for non-2xx responses.
+type Error struct {
+	StatusCode int
+	Method     string
+	Path       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("github: %s %s: %d %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var auth string
+	if c.app != nil {
+		id := installationFrom(ctx)
+		if id == 0 {
+			id = c.installationID
+		}
+		if id == 0 {
+			return fmt.Errorf("github: %s %s: no app installation ID known", method, path)
+		}
+		token, err := c.app.InstallationToken(ctx, id)
+		if err != nil {
+			return err
+		}
+		auth = "Bearer " + token
+	} else if c.token != "" {
+		auth = "Bearer " + c.token
+	}
+	return c.request(ctx, method, path, auth, in, out)
+}
+
+func (c *Client) request(ctx context.Context, method, path, auth string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", "hookrunner")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &apiErr)
+		return &Error{StatusCode: resp.StatusCode, Method: method, Path: path, Message: apiErr.Message}
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+	}
+	return nil
+}
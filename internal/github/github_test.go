@@ -0,0 +1,85 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIssueComments(t *testing.T) {
+	var gotAuth string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/org/repo/issues/42/comments", func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Comment{ID: 7, Body: in["body"]})
+	})
+	mux.HandleFunc("PATCH /repos/org/repo/issues/comments/7", func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		json.NewEncoder(w).Encode(Comment{ID: 7, Body: in["body"]})
+	})
+	mux.HandleFunc("GET /repos/org/repo/issues/42/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comments []Comment
+		if r.URL.Query().Get("page") == "1" {
+			for i := 0; i < 100; i++ {
+				comments = append(comments, Comment{ID: int64(i), Body: fmt.Sprintf("c%d", i)})
+			}
+		} else {
+			comments = []Comment{{ID: 100, Body: "last"}}
+		}
+		json.NewEncoder(w).Encode(comments)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := NewClient(srv.URL+"/", "tok")
+	ctx := context.Background()
+
+	t.Run("create", func(t *testing.T) {
+		comment, err := c.CreateIssueComment(ctx, "org/repo", 42, "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if comment.ID != 7 || comment.Body != "hello" {
+			t.Errorf("unexpected comment: %+v", comment)
+		}
+		if gotAuth != "Bearer tok" {
+			t.Errorf("Authorization = %q", gotAuth)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		comment, err := c.UpdateIssueComment(ctx, "org/repo", 7, "edited")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if comment.Body != "edited" {
+			t.Errorf("unexpected comment: %+v", comment)
+		}
+	})
+
+	t.Run("list follows pages", func(t *testing.T) {
+		comments, err := c.ListIssueComments(ctx, "org/repo", 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(comments) != 101 || comments[100].Body != "last" {
+			t.Errorf("got %d comments", len(comments))
+		}
+	})
+
+	t.Run("API error", func(t *testing.T) {
+		_, err := c.CreateIssueComment(ctx, "org/missing", 1, "x")
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404 API error, got %v", err)
+		}
+	})
+}
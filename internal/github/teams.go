@@ -0,0 +1,49 @@
+package github
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TeamCache answers team membership questions through a Client, remembering
+// each answer for a while so repeated triggers don't cost an API call each.
+type TeamCache struct {
+	client *Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]teamEntry
+}
+
+type teamEntry struct {
+	member  bool
+	expires time.Time
+}
+
+func NewTeamCache(client *Client, ttl time.Duration) *TeamCache {
+	return &TeamCache{client: client, ttl: ttl, now: time.Now, entries: make(map[string]teamEntry)}
+}
+
+// IsMember reports whether user is an active member of org/team. Errors are
+// not cached.
+func (t *TeamCache) IsMember(ctx context.Context, org, team, user string) (bool, error) {
+	key := strings.ToLower(org + "/" + team + "/" + user)
+	t.mu.Lock()
+	entry, ok := t.entries[key]
+	t.mu.Unlock()
+	if ok && t.now().Before(entry.expires) {
+		return entry.member, nil
+	}
+
+	member, err := t.client.IsTeamMember(ctx, org, team, user)
+	if err != nil {
+		return false, err
+	}
+	t.mu.Lock()
+	t.entries[key] = teamEntry{member: member, expires: t.now().Add(t.ttl)}
+	t.mu.Unlock()
+	return member, nil
+}
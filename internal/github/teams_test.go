@@ -0,0 +1,58 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTeamCache(t *testing.T) {
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orgs/org/teams/reviewers/memberships/{user}", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.PathValue("user") {
+		case "alice":
+			json.NewEncoder(w).Encode(map[string]string{"state": "active"})
+		case "pending":
+			json.NewEncoder(w).Encode(map[string]string{"state": "pending"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Not Found"})
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	now := time.Now()
+	cache := NewTeamCache(NewClient(srv.URL, "tok"), time.Minute)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		user string
+		want bool
+	}{{"alice", true}, {"pending", false}, {"mallory", false}, {"alice", true}, {"mallory", false}} {
+		got, err := cache.IsMember(ctx, "org", "reviewers", tt.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("IsMember(%s) = %v, want %v", tt.user, got, tt.want)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 API calls with caching, got %d", calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.IsMember(ctx, "org", "reviewers", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 {
+		t.Errorf("expected expired entry to be looked up again, got %d calls", calls)
+	}
+}
@@ -0,0 +1,102 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Entry struct {
+	Time       time.Time       `json:"time"`
+	DeliveryID string          `json:"delivery_id"`
+	Provider   string          `json:"provider,omitempty"`
+	Event      string          `json:"event"`
+	Headers    http.Header     `json:"headers"`
+	Body       json.RawMessage `json:"body"`
+	Workflows  []string        `json:"workflows"`
+}
+
+// Journal appends accepted deliveries to one JSONL file per day.
+type Journal struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func Open(dir string) *Journal {
+	return &Journal{dir: dir}
+}
+
+func (j *Journal) Append(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding journal entry: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return fmt.Errorf("creating journal dir: %w", err)
+	}
+	path := filepath.Join(j.dir, e.Time.Format("2006-01-02")+".jsonl")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	return nil
+}
+
+// Find returns the most recent entry for deliveryID.
+func (j *Journal) Find(deliveryID string) (*Entry, error) {
+	files, err := filepath.Glob(filepath.Join(j.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+
+	for _, path := range files {
+		entry, err := findInFile(path, deliveryID)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("delivery %s not found in %s", deliveryID, j.dir)
+}
+
+func findInFile(path, deliveryID string) (*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+	defer f.Close()
+
+	var found *Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		if e.DeliveryID == deliveryID {
+			found = &e
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return found, nil
+}
@@ -0,0 +1,48 @@
+package journal
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAppendAndFind(t *testing.T) {
+	j := Open(t.TempDir())
+
+	day1 := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	entries := []Entry{
+		{Time: day1, DeliveryID: "a", Event: "issue_comment", Body: json.RawMessage(`{"n":1}`)},
+		{Time: day1, DeliveryID: "b", Event: "pull_request", Body: json.RawMessage(`{"n":2}`)},
+		{Time: day2, DeliveryID: "a", Event: "issue_comment", Body: json.RawMessage(`{"n":3}`),
+			Headers: http.Header{"X-Github-Event": {"issue_comment"}}, Workflows: []string{"review"}},
+	}
+	for _, e := range entries {
+		if err := j.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := j.Find("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.Body) != `{"n":3}` {
+		t.Errorf("expected most recent entry, got body %s", got.Body)
+	}
+	if got.Headers.Get("X-GitHub-Event") != "issue_comment" {
+		t.Errorf("headers not preserved: %v", got.Headers)
+	}
+	if len(got.Workflows) != 1 || got.Workflows[0] != "review" {
+		t.Errorf("workflows = %v", got.Workflows)
+	}
+
+	if got, err := j.Find("b"); err != nil || got.Event != "pull_request" {
+		t.Errorf("Find(b) = %+v, %v", got, err)
+	}
+
+	if _, err := j.Find("missing"); err == nil {
+		t.Error("expected error for unknown delivery")
+	}
+}
@@ -0,0 +1,108 @@
+// Package jsonpath evaluates the small subset of JSONPath used to pick values
+// out of webhook payloads: $.alert.severity, $.alerts[0].labels and
+// $['key.with.dots'].
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a parsed JSONPath expression.
+type Path []step
+
+type step struct {
+	key   string
+	index int // used when key is ""
+}
+
+// Parse parses a path made of a leading $ followed by .key, ['key'] and
+// [index] steps.
+func Parse(s string) (Path, error) {
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("path %q must start with $", s)
+	}
+	var p Path
+	rest := s[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("path %q has an empty key", s)
+			}
+			p = append(p, step{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			if len(rest) > 1 && (rest[1] == '\'' || rest[1] == '"') {
+				end := strings.IndexByte(rest[2:], rest[1])
+				if end < 0 || !strings.HasPrefix(rest[2+end+1:], "]") || end == 0 {
+					return nil, fmt.Errorf("path %q has an unterminated or empty quoted key", s)
+				}
+				p = append(p, step{key: rest[2 : 2+end]})
+				rest = rest[2+end+2:]
+				continue
+			}
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q has an unterminated [", s)
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("path %q has an invalid index %q", s, rest[1:end])
+			}
+			p = append(p, step{index: n})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("path %q: unexpected %q", s, rest[0])
+		}
+	}
+	return p, nil
+}
+
+// Get follows p through doc, a value decoded by encoding/json. It reports
+// false when a key or index does not exist.
+func (p Path) Get(doc interface{}) (interface{}, bool) {
+	for _, st := range p {
+		if st.key != "" {
+			obj, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = obj[st.key]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		arr, ok := doc.([]interface{})
+		if !ok || st.index >= len(arr) {
+			return nil, false
+		}
+		doc = arr[st.index]
+	}
+	return doc, true
+}
+
+// String formats a value returned by Get for use as a template variable:
+// strings as they are, null as "", and anything else as compact JSON.
+func String(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,58 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{
+		"alert": {"severity": "critical", "count": 3, "firing": true, "labels": {"team": "infra"}},
+		"alerts": [{"name": "disk"}, {"name": "cpu"}],
+		"a.b": "dotted",
+		"none": null
+	}`))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"$.alert.severity", "critical", true},
+		{"$.alert.count", "3", true},
+		{"$.alert.firing", "true", true},
+		{"$.alert.labels", `{"team":"infra"}`, true},
+		{"$.alerts[1].name", "cpu", true},
+		{"$['a.b']", "dotted", true},
+		{`$["alert"]['severity']`, "critical", true},
+		{"$.none", "", true},
+		{"$.alerts[2].name", "", false},
+		{"$.alert.missing", "", false},
+		{"$.alert.severity.x", "", false},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.path)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.path, err)
+			continue
+		}
+		v, ok := p.Get(doc)
+		if ok != tt.ok || String(v) != tt.want {
+			t.Errorf("%s = %q, %v; want %q, %v", tt.path, String(v), ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, path := range []string{"alert.severity", "$.", "$..a", "$[x]", "$[-1]", "$[0", "$['a]", "$['']", "$a"} {
+		if _, err := Parse(path); err == nil {
+			t.Errorf("Parse(%q): expected error", path)
+		}
+	}
+}
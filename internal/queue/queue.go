@@ -0,0 +1,354 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/workflow"
+)
+
+// ExecFunc runs a single job. It must return once ctx is canceled.
+type ExecFunc func(ctx context.Context, job *Job, wf config.WorkflowConfig) workflow.Result
+
+// ErrShuttingDown is the cancellation cause of jobs interrupted by Shutdown.
+// They stay in the state file and run again after a restart.
+var ErrShuttingDown = errors.New("shutting down")
+
+type SubmitHook func(job *Job, wf config.WorkflowConfig)
+
+// DropHook is called for a job that was submitted but will never run.
+type DropHook func(job *Job, wf config.WorkflowConfig)
+
+type Job struct {
+	ID       string                `json:"id"`
+	Workflow string                `json:"workflow"`
+	Vars     workflow.TemplateVars `json:"vars"`
+	Delivery string                `json:"delivery,omitempty"`
+	// Author is the user who caused the event: the commenter, pusher or
+	// sender, whatever the event type.
+	Author string `json:"author,omitempty"`
+	// Installation is the GitHub App installation the event came from, used
+	// to authenticate API calls made on the job's behalf.
+	Installation int64     `json:"installation,omitempty"`
+	Group        string    `json:"group,omitempty"`
+	Enqueued     time.Time `json:"enqueued"`
+}
+
+type running struct {
+	job    *Job
+	cancel context.CancelCauseFunc
+}
+
+// Queue runs jobs in FIFO order while respecting the global
+// max_concurrent_jobs limit, each workflow's concurrency limit and
+// concurrency groups, of which at most one job runs at a time. Jobs that have
+// not finished are persisted so they survive a restart.
+type Queue struct {
+	cfg  *config.Config
+	exec ExecFunc
+	path string
+
+	mu          sync.Mutex
+	pending     []*Job
+	running     map[string]*running
+	perWorkflow map[string]int
+	closed      bool
+	wg          sync.WaitGroup
+	submitHooks []SubmitHook
+	dropHooks   []DropHook
+}
+
+func New(cfg *config.Config, exec ExecFunc) *Queue {
+	return &Queue{
+		cfg:         cfg,
+		exec:        exec,
+		running:     make(map[string]*running),
+		perWorkflow: make(map[string]int),
+	}
+}
+
+// Persist re-enqueues jobs left over in the state file at path, including
+// jobs that were running when the previous process stopped, and keeps the
+// file up to date from then on. Without it the queue only lives in memory.
+func (q *Queue) Persist(path string) error {
+	if path == "" {
+		return nil
+	}
+	q.mu.Lock()
+	q.path = path
+	q.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading queue state: %w", err)
+	}
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("parsing queue state: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range jobs {
+		if _, ok := q.cfg.Workflows[job.Workflow]; !ok {
+			log.Printf("Queue: dropping job %s for unknown workflow %q", job.ID, job.Workflow)
+			continue
+		}
+		log.Printf("Queue: restored job %s (%q)", job.ID, job.Workflow)
+		q.wg.Add(1)
+		q.pending = append(q.pending, job)
+	}
+	q.persistLocked()
+	q.scheduleLocked()
+	return nil
+}
+
+// ValidateGroups renders every workflow's concurrency_group with empty
+// template variables, so that a misspelled field is reported at startup
+// instead of failing every Submit. Syntax is already checked when the config
+// is loaded; the fields live in the workflow package, which config cannot
+// import.
+func ValidateGroups(cfg *config.Config) error {
+	for name, wf := range cfg.Workflows {
+		if wf.ConcurrencyGroup == "" {
+			continue
+		}
+		if _, err := workflow.RenderTemplate(wf.ConcurrencyGroup, workflow.TemplateVars{}); err != nil {
+			return fmt.Errorf("workflow %q: invalid concurrency_group: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Submit assigns job an ID and concurrency group and queues it. The caller
+// fills in Workflow, Vars and Delivery.
+func (q *Queue) Submit(job *Job) error {
+	wf, ok := q.cfg.Workflows[job.Workflow]
+	if !ok {
+		return fmt.Errorf("unknown workflow %q", job.Workflow)
+	}
+	job.ID = newID()
+	job.Enqueued = time.Now()
+	if wf.ConcurrencyGroup != "" {
+		group, err := workflow.RenderTemplate(wf.ConcurrencyGroup, job.Vars)
+		if err != nil {
+			return fmt.Errorf("concurrency_group template error: %w", err)
+		}
+		job.Group = group
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return fmt.Errorf("queue is shut down")
+	}
+	if job.Group != "" && wf.CancelInProgress {
+		q.cancelGroupLocked(job)
+	}
+	for _, hook := range q.submitHooks {
+		hook(job, wf)
+	}
+	q.wg.Add(1)
+	q.pending = append(q.pending, job)
+	q.persistLocked()
+	q.scheduleLocked()
+	if _, ok := q.running[job.ID]; !ok {
+		log.Printf("Queue: job %s (%q) waiting, %d pending", job.ID, job.Workflow, len(q.pending))
+	}
+	return nil
+}
+
+// OnSubmit registers hook to be called for every accepted job before it can
+// start. Hooks run with the queue locked, so they must not block or call back
+// into the queue.
+func (q *Queue) OnSubmit(hook SubmitHook) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.submitHooks = append(q.submitHooks, hook)
+}
+
+// OnDrop registers hook to be called for every pending job dropped because a
+// newer job in its concurrency group canceled it, so that whatever a
+// SubmitHook started for it can be resolved. Like submit hooks, drop hooks
+// run with the queue locked.
+func (q *Queue) OnDrop(hook DropHook) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dropHooks = append(q.dropHooks, hook)
+}
+
+// Wait blocks until every submitted job has finished.
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+// Shutdown stops scheduling, cancels running jobs and waits for them to exit.
+// Unfinished jobs stay in the state file and are picked up by Persist.
+func (q *Queue) Shutdown(ctx context.Context) {
+	q.mu.Lock()
+	q.closed = true
+	q.persistLocked()
+	for range q.pending {
+		q.wg.Done()
+	}
+	for _, r := range q.running {
+		r.cancel(ErrShuttingDown)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Queue: shutdown timed out with jobs still running")
+	}
+}
+
+// cancelGroupLocked makes room for job by canceling the running job in its
+// concurrency group and dropping any jobs of that group still waiting.
+func (q *Queue) cancelGroupLocked(job *Job) {
+	for _, r := range q.running {
+		if r.job.Group == job.Group {
+			log.Printf("Queue: canceling job %s (%q) in group %q, superseded by job %s",
+				r.job.ID, r.job.Workflow, job.Group, job.ID)
+			r.cancel(fmt.Errorf("superseded by job %s", job.ID))
+		}
+	}
+	remaining := q.pending[:0]
+	for _, p := range q.pending {
+		if p.Group == job.Group {
+			log.Printf("Queue: dropping pending job %s (%q) in group %q, superseded by job %s",
+				p.ID, p.Workflow, job.Group, job.ID)
+			for _, hook := range q.dropHooks {
+				hook(p, q.cfg.Workflows[p.Workflow])
+			}
+			q.wg.Done()
+			continue
+		}
+		remaining = append(remaining, p)
+	}
+	q.pending = remaining
+}
+
+func (q *Queue) scheduleLocked() {
+	if q.closed {
+		return
+	}
+	// Groups with an earlier job still waiting stay blocked so that jobs in
+	// the same group start in submission order.
+	blocked := make(map[string]bool)
+	remaining := q.pending[:0]
+	for _, job := range q.pending {
+		wf, ok := q.cfg.Workflows[job.Workflow]
+		if !ok {
+			log.Printf("Queue: dropping job %s for unknown workflow %q", job.ID, job.Workflow)
+			q.wg.Done()
+			continue
+		}
+		if (job.Group != "" && blocked[job.Group]) || !q.canStartLocked(job, wf) {
+			if job.Group != "" {
+				blocked[job.Group] = true
+			}
+			remaining = append(remaining, job)
+			continue
+		}
+		q.startLocked(job, wf)
+	}
+	q.pending = remaining
+}
+
+func (q *Queue) canStartLocked(job *Job, wf config.WorkflowConfig) bool {
+	if q.cfg.MaxConcurrentJobs > 0 && len(q.running) >= q.cfg.MaxConcurrentJobs {
+		return false
+	}
+	if wf.Concurrency > 0 && q.perWorkflow[job.Workflow] >= wf.Concurrency {
+		return false
+	}
+	if job.Group != "" {
+		for _, r := range q.running {
+			if r.job.Group == job.Group {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (q *Queue) startLocked(job *Job, wf config.WorkflowConfig) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	q.running[job.ID] = &running{job: job, cancel: cancel}
+	q.perWorkflow[job.Workflow]++
+
+	go func() {
+		defer q.wg.Done()
+		defer cancel(nil)
+		q.exec(ctx, job, wf)
+		q.finish(job)
+	}()
+}
+
+func (q *Queue) finish(job *Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, job.ID)
+	q.perWorkflow[job.Workflow]--
+	if q.closed {
+		return
+	}
+	q.persistLocked()
+	q.scheduleLocked()
+}
+
+func (q *Queue) persistLocked() {
+	if q.path == "" {
+		return
+	}
+	jobs := make([]*Job, 0, len(q.running)+len(q.pending))
+	for _, r := range q.running {
+		jobs = append(jobs, r.job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Enqueued.Before(jobs[j].Enqueued) })
+	jobs = append(jobs, q.pending...)
+	if err := writeJSON(q.path, jobs); err != nil {
+		log.Printf("Queue: failed to persist state: %v", err)
+	}
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func newID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
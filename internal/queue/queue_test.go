@@ -0,0 +1,281 @@
+package queue
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/workflow"
+)
+
+type blockingExec struct {
+	mu      sync.Mutex
+	started []string
+	release chan struct{}
+}
+
+func newBlockingExec() *blockingExec {
+	return &blockingExec{release: make(chan struct{})}
+}
+
+func (b *blockingExec) exec(ctx context.Context, job *Job, wf config.WorkflowConfig) workflow.Result {
+	b.mu.Lock()
+	b.started = append(b.started, job.Vars.PRNumber)
+	b.mu.Unlock()
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+	}
+	return workflow.Result{}
+}
+
+func (b *blockingExec) startedSnapshot() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.started...)
+}
+
+func waitForStarted(t *testing.T, b *blockingExec, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if s := b.startedSnapshot(); len(s) >= n {
+			return s
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d started jobs, got %v", n, b.startedSnapshot())
+	return nil
+}
+
+func TestQueueConcurrency(t *testing.T) {
+	t.Run("global limit", func(t *testing.T) {
+		cfg := &config.Config{
+			MaxConcurrentJobs: 2,
+			Workflows: map[string]config.WorkflowConfig{
+				"a": {Trigger: "x", Command: "true"},
+			},
+		}
+		b := newBlockingExec()
+		q := New(cfg, b.exec)
+		for _, pr := range []string{"1", "2", "3"} {
+			if err := q.Submit(&Job{Workflow: "a", Vars: workflow.TemplateVars{PRNumber: pr}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		waitForStarted(t, b, 2)
+		time.Sleep(20 * time.Millisecond)
+		if got := b.startedSnapshot(); len(got) != 2 {
+			t.Fatalf("expected 2 running jobs, got %v", got)
+		}
+
+		b.release <- struct{}{}
+		got := waitForStarted(t, b, 3)
+		if got[2] != "3" {
+			t.Errorf("expected job 3 to start last, got %v", got)
+		}
+		close(b.release)
+		q.Wait()
+	})
+
+	t.Run("per-workflow limit", func(t *testing.T) {
+		cfg := &config.Config{
+			MaxConcurrentJobs: 4,
+			Workflows: map[string]config.WorkflowConfig{
+				"slow": {Trigger: "x", Command: "true", Concurrency: 1},
+				"fast": {Trigger: "x", Command: "true"},
+			},
+		}
+		b := newBlockingExec()
+		q := New(cfg, b.exec)
+		q.Submit(&Job{Workflow: "slow", Vars: workflow.TemplateVars{PRNumber: "1"}})
+		q.Submit(&Job{Workflow: "slow", Vars: workflow.TemplateVars{PRNumber: "2"}})
+		q.Submit(&Job{Workflow: "fast", Vars: workflow.TemplateVars{PRNumber: "3"}})
+
+		waitForStarted(t, b, 2)
+		time.Sleep(20 * time.Millisecond)
+		got := b.startedSnapshot()
+		sort.Strings(got)
+		if len(got) != 2 || got[0] != "1" || got[1] != "3" {
+			t.Fatalf("expected jobs 1 and 3 to run, got %v", got)
+		}
+
+		close(b.release)
+		q.Wait()
+		if got := b.startedSnapshot(); len(got) != 3 {
+			t.Errorf("expected all jobs to run, got %v", got)
+		}
+	})
+}
+
+func TestQueuePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	cfg := &config.Config{
+		MaxConcurrentJobs: 1,
+		Workflows: map[string]config.WorkflowConfig{
+			"a": {Trigger: "x", Command: "true"},
+		},
+	}
+
+	b := newBlockingExec()
+	q := New(cfg, b.exec)
+	if err := q.Persist(path); err != nil {
+		t.Fatal(err)
+	}
+	q.Submit(&Job{Workflow: "a", Vars: workflow.TemplateVars{PRNumber: "1"}})
+	q.Submit(&Job{Workflow: "a", Vars: workflow.TemplateVars{PRNumber: "2"}})
+	waitForStarted(t, b, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	q.Shutdown(ctx)
+
+	if err := q.Submit(&Job{Workflow: "a", Vars: workflow.TemplateVars{PRNumber: "3"}}); err == nil {
+		t.Error("expected submit to fail after shutdown")
+	}
+
+	restored := newBlockingExec()
+	q2 := New(cfg, restored.exec)
+	if err := q2.Persist(path); err != nil {
+		t.Fatal(err)
+	}
+	got := waitForStarted(t, restored, 1)
+	if got[0] != "1" {
+		t.Errorf("expected interrupted job 1 to run first, got %v", got)
+	}
+	close(restored.release)
+	q2.Wait()
+	if got := restored.startedSnapshot(); len(got) != 2 || got[1] != "2" {
+		t.Errorf("expected jobs 1 and 2 to be restored, got %v", got)
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	cfg := &config.Config{
+		Workflows: map[string]config.WorkflowConfig{
+			"review": {Trigger: "x", Command: "true", ConcurrencyGroup: "{{.RepoFullName}}-{{.PRNumber}}"},
+		},
+	}
+	if err := ValidateGroups(cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cfg.Workflows["typo"] = config.WorkflowConfig{Trigger: "x", Command: "true", ConcurrencyGroup: "{{.PRnumber}}"}
+	if err := ValidateGroups(cfg); err == nil {
+		t.Error("expected error for unknown field in concurrency_group")
+	}
+}
+
+func TestQueueConcurrencyGroups(t *testing.T) {
+	t.Run("queues behind running job", func(t *testing.T) {
+		cfg := &config.Config{
+			MaxConcurrentJobs: 4,
+			Workflows: map[string]config.WorkflowConfig{
+				"review": {Trigger: "x", Command: "true", ConcurrencyGroup: "{{.RepoFullName}}-{{.PRNumber}}"},
+			},
+		}
+		b := newBlockingExec()
+		q := New(cfg, b.exec)
+		first := &Job{Workflow: "review", Vars: workflow.TemplateVars{RepoFullName: "org/repo", PRNumber: "1"}}
+		q.Submit(first)
+		q.Submit(&Job{Workflow: "review", Vars: workflow.TemplateVars{RepoFullName: "org/repo", PRNumber: "1"}})
+		q.Submit(&Job{Workflow: "review", Vars: workflow.TemplateVars{RepoFullName: "org/repo", PRNumber: "2"}})
+
+		if first.Group != "org/repo-1" {
+			t.Errorf("group = %q, want %q", first.Group, "org/repo-1")
+		}
+
+		waitForStarted(t, b, 2)
+		time.Sleep(20 * time.Millisecond)
+		got := b.startedSnapshot()
+		sort.Strings(got)
+		if len(got) != 2 || got[0] != "1" || got[1] != "2" {
+			t.Fatalf("expected one job per group to run, got %v", got)
+		}
+
+		close(b.release)
+		q.Wait()
+		if got := b.startedSnapshot(); len(got) != 3 {
+			t.Errorf("expected queued job to run after the first, got %v", got)
+		}
+	})
+
+	t.Run("cancel in progress", func(t *testing.T) {
+		cfg := &config.Config{
+			MaxConcurrentJobs: 4,
+			Workflows: map[string]config.WorkflowConfig{
+				"review": {
+					Trigger:          "x",
+					Command:          "true",
+					ConcurrencyGroup: "{{.RepoFullName}}-{{.PRNumber}}",
+					CancelInProgress: true,
+				},
+			},
+		}
+
+		var mu sync.Mutex
+		var results []string
+		exec := func(ctx context.Context, job *Job, wf config.WorkflowConfig) workflow.Result {
+			select {
+			case <-ctx.Done():
+				mu.Lock()
+				results = append(results, job.Vars.CommentBody+":canceled")
+				mu.Unlock()
+			case <-time.After(200 * time.Millisecond):
+				mu.Lock()
+				results = append(results, job.Vars.CommentBody+":done")
+				mu.Unlock()
+			}
+			return workflow.Result{}
+		}
+
+		q := New(cfg, exec)
+		q.Submit(&Job{Workflow: "review", Vars: workflow.TemplateVars{RepoFullName: "org/repo", PRNumber: "1", CommentBody: "first"}})
+		time.Sleep(20 * time.Millisecond)
+		q.Submit(&Job{Workflow: "review", Vars: workflow.TemplateVars{RepoFullName: "org/repo", PRNumber: "1", CommentBody: "second"}})
+		q.Wait()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(results) != 2 || results[0] != "first:canceled" || results[1] != "second:done" {
+			t.Errorf("unexpected results: %v", results)
+		}
+	})
+
+	t.Run("drop hooks for superseded pending jobs", func(t *testing.T) {
+		cfg := &config.Config{
+			MaxConcurrentJobs: 4,
+			Workflows: map[string]config.WorkflowConfig{
+				"review": {
+					Trigger:          "x",
+					Command:          "true",
+					ConcurrencyGroup: "{{.PRNumber}}",
+					CancelInProgress: true,
+				},
+				"lint": {Trigger: "x", Command: "true", ConcurrencyGroup: "{{.PRNumber}}"},
+			},
+		}
+		b := newBlockingExec()
+		q := New(cfg, b.exec)
+		var dropped []string
+		q.OnDrop(func(job *Job, wf config.WorkflowConfig) {
+			dropped = append(dropped, job.Workflow+":"+job.Vars.CommentBody)
+		})
+
+		q.Submit(&Job{Workflow: "lint", Vars: workflow.TemplateVars{PRNumber: "1", CommentBody: "running"}})
+		waitForStarted(t, b, 1)
+		q.Submit(&Job{Workflow: "lint", Vars: workflow.TemplateVars{PRNumber: "1", CommentBody: "waiting"}})
+		q.Submit(&Job{Workflow: "review", Vars: workflow.TemplateVars{PRNumber: "1", CommentBody: "newest"}})
+		waitForStarted(t, b, 2)
+		close(b.release)
+		q.Wait()
+
+		if len(dropped) != 1 || dropped[0] != "lint:waiting" {
+			t.Errorf("dropped = %v, want [lint:waiting]", dropped)
+		}
+	})
+}
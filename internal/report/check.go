@@ -0,0 +1,197 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/github"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/workflow"
+)
+
+// Checker reports runs of workflows with report.check set as a check run on
+// the PR's head commit: queued when the job is dispatched, then completed
+// with the run's conclusion. Tokens that may not create check runs (anything
+// but a GitHub App) fall back to commit statuses.
+type Checker struct {
+	client *github.Client
+
+	mu     sync.Mutex
+	checks map[string]*check // by job ID
+}
+
+type check struct {
+	done       chan struct{}
+	checkRunID int64
+}
+
+func NewChecker(client *github.Client) *Checker {
+	return &Checker{client: client, checks: make(map[string]*check)}
+}
+
+func checkName(job *queue.Job) string {
+	return "hookrunner/" + job.Workflow
+}
+
+// Queued is a queue.SubmitHook that marks the head commit as pending in the
+// background.
+func (c *Checker) Queued(job *queue.Job, wf config.WorkflowConfig) {
+	if !wf.Report.Check || !fromGitHub(job) || job.Vars.HeadSHA == "" || job.Vars.RepoFullName == "" {
+		return
+	}
+
+	ch := &check{done: make(chan struct{})}
+	c.mu.Lock()
+	c.checks[job.ID] = ch
+	c.mu.Unlock()
+
+	go func() {
+		defer close(ch.done)
+		ctx, cancel := apiContext(job, 30*time.Second)
+		defer cancel()
+
+		repo := job.Vars.RepoFullName
+		run, err := c.client.CreateCheckRun(ctx, repo, github.CheckRun{
+			Name:    checkName(job),
+			HeadSHA: job.Vars.HeadSHA,
+			Status:  "queued",
+			Output:  &github.CheckRunOutput{Title: "Queued", Summary: fmt.Sprintf("Run `%s` is queued.", job.ID)},
+		})
+		if err == nil {
+			ch.checkRunID = run.ID
+			return
+		}
+		if !checkRunsUnavailable(err) {
+			log.Printf("Report: failed to create check run for job %s: %v", job.ID, err)
+			return
+		}
+		err = c.client.CreateStatus(ctx, repo, job.Vars.HeadSHA, github.Status{
+			State:       "pending",
+			Context:     checkName(job),
+			Description: fmt.Sprintf("Run %s queued", job.ID),
+		})
+		if err != nil {
+			log.Printf("Report: failed to set commit status for job %s: %v", job.ID, err)
+		}
+	}()
+}
+
+// Dropped is a queue.DropHook for jobs superseded before they started.
+func (c *Checker) Dropped(job *queue.Job, wf config.WorkflowConfig) {
+	if wf.Report.Check && fromGitHub(job) && job.Vars.HeadSHA != "" && job.Vars.RepoFullName != "" {
+		go c.finish(job, workflow.Result{Canceled: true}, nil)
+	}
+}
+
+func (c *Checker) Wrap(next queue.ExecFunc) queue.ExecFunc {
+	return func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+		res := next(ctx, job, wf)
+		if wf.Report.Check && fromGitHub(job) && job.Vars.HeadSHA != "" && job.Vars.RepoFullName != "" {
+			c.finish(job, res, context.Cause(ctx))
+		}
+		return res
+	}
+}
+
+// finish completes job's check run. cause is why a canceled job's context
+// was canceled.
+func (c *Checker) finish(job *queue.Job, res workflow.Result, cause error) {
+	c.mu.Lock()
+	ch := c.checks[job.ID]
+	delete(c.checks, job.ID)
+	c.mu.Unlock()
+
+	var checkRunID int64
+	if ch != nil {
+		<-ch.done
+		checkRunID = ch.checkRunID
+	}
+
+	// A job interrupted by shutdown runs again after a restart; superseded
+	// jobs are concluded as cancelled.
+	if res.Canceled && errors.Is(cause, queue.ErrShuttingDown) {
+		return
+	}
+
+	ctx, cancel := apiContext(job, 30*time.Second)
+	defer cancel()
+
+	repo := job.Vars.RepoFullName
+	conclusion, state := conclusionFor(res)
+	summary := fmt.Sprintf("Run `%s` %s after %.1fs with exit code %d.",
+		job.ID, describe(res), res.Duration().Seconds(), res.ExitCode)
+	if res.Canceled && res.Start.IsZero() {
+		summary = fmt.Sprintf("Run `%s` was superseded before it started.", job.ID)
+	}
+	completed := github.CheckRun{
+		Name:       checkName(job),
+		HeadSHA:    job.Vars.HeadSHA,
+		Status:     "completed",
+		Conclusion: conclusion,
+		Output:     &github.CheckRunOutput{Title: describe(res), Summary: summary},
+	}
+
+	var err error
+	if checkRunID != 0 {
+		_, err = c.client.UpdateCheckRun(ctx, repo, checkRunID, completed)
+	} else {
+		_, err = c.client.CreateCheckRun(ctx, repo, completed)
+	}
+	if err == nil {
+		return
+	}
+	if !checkRunsUnavailable(err) {
+		log.Printf("Report: failed to complete check run for job %s: %v", job.ID, err)
+		return
+	}
+	err = c.client.CreateStatus(ctx, repo, job.Vars.HeadSHA, github.Status{
+		State:       state,
+		Context:     checkName(job),
+		Description: fmt.Sprintf("Run %s %s", job.ID, describe(res)),
+	})
+	if err != nil {
+		log.Printf("Report: failed to set commit status for job %s: %v", job.ID, err)
+	}
+}
+
+// checkRunsUnavailable reports whether err means the token cannot use the
+// checks API, in which case commit statuses are used instead.
+func checkRunsUnavailable(err error) bool {
+	var apiErr *github.Error
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusForbidden || apiErr.StatusCode == http.StatusNotFound)
+}
+
+// conclusionFor maps a result to a check run conclusion and a commit status
+// state.
+func conclusionFor(res workflow.Result) (string, string) {
+	switch {
+	case res.TimedOut:
+		return "timed_out", "failure"
+	case res.Canceled:
+		return "cancelled", "error"
+	case res.Err != nil || res.ExitCode != 0:
+		return "failure", "failure"
+	default:
+		return "success", "success"
+	}
+}
+
+func describe(res workflow.Result) string {
+	switch {
+	case res.TimedOut:
+		return "timed out"
+	case res.Canceled:
+		return "was canceled"
+	case res.Err != nil || res.ExitCode != 0:
+		return "failed"
+	default:
+		return "succeeded"
+	}
+}
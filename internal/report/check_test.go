@@ -0,0 +1,173 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/github"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/workflow"
+)
+
+type fakeChecks struct {
+	mu        sync.Mutex
+	calls     []string
+	noChecks  bool
+	nextRunID int64
+}
+
+func (f *fakeChecks) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var in map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&in)
+
+		if strings.Contains(r.URL.Path, "/statuses/") {
+			f.calls = append(f.calls, fmt.Sprintf("%s %s %v", r.Method, r.URL.Path, in["state"]))
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		if f.noChecks {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Resource not accessible by personal access token"})
+			return
+		}
+		f.calls = append(f.calls, fmt.Sprintf("%s %s %v %v", r.Method, r.URL.Path, in["status"], in["conclusion"]))
+		if r.Method == http.MethodPost {
+			f.nextRunID++
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(github.CheckRun{ID: f.nextRunID})
+	})
+}
+
+func (f *fakeChecks) snapshot() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func TestChecker(t *testing.T) {
+	vars := workflow.TemplateVars{RepoFullName: "org/repo", PRNumber: "42", EventType: "pull_request", HeadSHA: "abc123"}
+	tests := []struct {
+		name     string
+		noChecks bool
+		result   workflow.Result
+		want     []string
+	}{
+		{
+			name:   "check run success",
+			result: workflow.Result{ExitCode: 0},
+			want: []string{
+				"POST /repos/org/repo/check-runs queued <nil>",
+				"PATCH /repos/org/repo/check-runs/1 completed success",
+			},
+		},
+		{
+			name:   "check run timed out",
+			result: workflow.Result{ExitCode: -1, TimedOut: true},
+			want: []string{
+				"POST /repos/org/repo/check-runs queued <nil>",
+				"PATCH /repos/org/repo/check-runs/1 completed timed_out",
+			},
+		},
+		{
+			name:   "check run superseded",
+			result: workflow.Result{ExitCode: -1, Canceled: true},
+			want: []string{
+				"POST /repos/org/repo/check-runs queued <nil>",
+				"PATCH /repos/org/repo/check-runs/1 completed cancelled",
+			},
+		},
+		{
+			name:     "status fallback failure",
+			noChecks: true,
+			result:   workflow.Result{ExitCode: 1},
+			want: []string{
+				"POST /repos/org/repo/statuses/abc123 pending",
+				"POST /repos/org/repo/statuses/abc123 failure",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeChecks{noChecks: tt.noChecks}
+			srv := httptest.NewServer(fake.handler())
+			defer srv.Close()
+
+			cfg := &config.Config{
+				Workflows: map[string]config.WorkflowConfig{
+					"build": {Events: []string{"pull_request"}, Command: "true", Report: config.ReportConfig{Check: true}},
+				},
+			}
+			checker := NewChecker(github.NewClient(srv.URL, "tok"))
+			result := tt.result
+			q := queue.New(cfg, checker.Wrap(func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+				return result
+			}))
+			q.OnSubmit(checker.Queued)
+
+			if err := q.Submit(&queue.Job{Workflow: "build", Vars: vars}); err != nil {
+				t.Fatal(err)
+			}
+			q.Wait()
+
+			got := fake.snapshot()
+			if len(got) != len(tt.want) {
+				t.Fatalf("calls = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("call %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	t.Run("shutdown leaves check queued", func(t *testing.T) {
+		fake := &fakeChecks{}
+		srv := httptest.NewServer(fake.handler())
+		defer srv.Close()
+
+		checker := NewChecker(github.NewClient(srv.URL, "tok"))
+		wf := config.WorkflowConfig{Report: config.ReportConfig{Check: true}}
+		job := &queue.Job{ID: "j", Workflow: "build", Vars: vars}
+		checker.Queued(job, wf)
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(queue.ErrShuttingDown)
+		checker.Wrap(func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+			return workflow.Result{ExitCode: -1, Canceled: true}
+		})(ctx, job, wf)
+
+		if got := fake.snapshot(); len(got) != 1 || got[0] != "POST /repos/org/repo/check-runs queued <nil>" {
+			t.Errorf("calls = %v, want only the queued check run", got)
+		}
+	})
+
+	t.Run("no head sha", func(t *testing.T) {
+		fake := &fakeChecks{}
+		srv := httptest.NewServer(fake.handler())
+		defer srv.Close()
+
+		checker := NewChecker(github.NewClient(srv.URL, "tok"))
+		wf := config.WorkflowConfig{Report: config.ReportConfig{Check: true}}
+		job := &queue.Job{ID: "j", Workflow: "build", Vars: workflow.TemplateVars{RepoFullName: "org/repo", EventType: "issue_comment"}}
+		checker.Queued(job, wf)
+		checker.Wrap(func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+			return workflow.Result{}
+		})(context.Background(), job, wf)
+
+		if got := fake.snapshot(); len(got) != 0 {
+			t.Errorf("expected no API calls, got %v", got)
+		}
+	})
+}
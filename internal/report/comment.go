@@ -0,0 +1,208 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/github"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/workflow"
+)
+
+// maxTailBytes keeps comments well below GitHub's 65536 character limit.
+const maxTailBytes = 32 << 10
+
+// Commenter posts each finished run of a workflow with report.comment set as
+// a comment on the PR or issue that triggered it.
+type Commenter struct {
+	client  *github.Client
+	logPath func(runID string) string
+}
+
+// NewCommenter returns a Commenter that reads run output from the files
+// returned by logPath.
+func NewCommenter(client *github.Client, logPath func(runID string) string) *Commenter {
+	return &Commenter{client: client, logPath: logPath}
+}
+
+func (c *Commenter) Wrap(next queue.ExecFunc) queue.ExecFunc {
+	return func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+		res := next(ctx, job, wf)
+		// A canceled job was superseded by a newer one or will run again
+		// after a restart; the run that finishes reports instead.
+		if wf.Report.Comment && fromGitHub(job) && !res.Canceled {
+			c.post(job, wf, res)
+		}
+		return res
+	}
+}
+
+func (c *Commenter) post(job *queue.Job, wf config.WorkflowConfig, res workflow.Result) {
+	number, err := strconv.Atoi(job.Vars.PRNumber)
+	if err != nil || number == 0 || job.Vars.RepoFullName == "" {
+		log.Printf("Report: run %s has no PR or issue to comment on", job.ID)
+		return
+	}
+
+	ctx, cancel := apiContext(job, time.Minute)
+	defer cancel()
+
+	tail, err := readTail(c.logPath(job.ID), wf.Report.TailLines)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Report: failed to read output of run %s: %v", job.ID, err)
+	}
+	body := commentBody(job, res, tail, wf.Report.TailLines)
+	repo := job.Vars.RepoFullName
+
+	if wf.Report.Update {
+		existing, err := c.findComment(ctx, repo, number, marker(job.Workflow))
+		if err != nil {
+			log.Printf("Report: failed to list comments on %s#%d: %v", repo, number, err)
+		} else if existing != nil {
+			if _, err := c.client.UpdateIssueComment(ctx, repo, existing.ID, body); err != nil {
+				log.Printf("Report: failed to update comment %d on %s#%d: %v", existing.ID, repo, number, err)
+				return
+			}
+			log.Printf("Report: updated comment %d on %s#%d", existing.ID, repo, number)
+			return
+		}
+	}
+
+	comment, err := c.client.CreateIssueComment(ctx, repo, number, body)
+	if err != nil {
+		log.Printf("Report: failed to comment on %s#%d: %v", repo, number, err)
+		return
+	}
+	log.Printf("Report: posted comment %d on %s#%d", comment.ID, repo, number)
+}
+
+func (c *Commenter) findComment(ctx context.Context, repo string, number int, marker string) (*github.Comment, error) {
+	comments, err := c.client.ListIssueComments(ctx, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(comments) - 1; i >= 0; i-- {
+		if strings.Contains(comments[i].Body, marker) {
+			return &comments[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// marker is a hidden tag identifying the workflow a comment reports on, so
+// later runs can find and edit it.
+// MarkerPrefix starts the hidden marker in every comment hookrunner posts.
+// The webhook handler ignores comments carrying it, so a result comment that
+// echoes a trigger cannot start its workflow again.
+const MarkerPrefix = "<!-- hookrunner:workflow="
+
+func marker(workflowName string) string {
+	return MarkerPrefix + workflowName + " -->"
+}
+
+func status(res workflow.Result) string {
+	switch {
+	case res.TimedOut:
+		return "⏱️ timed out"
+	case res.Canceled:
+		return "🚫 canceled"
+	case res.Err != nil || res.ExitCode != 0:
+		return "❌ failed"
+	default:
+		return "✅ succeeded"
+	}
+}
+
+func commentBody(job *queue.Job, res workflow.Result, tail string, tailLines int) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, marker(job.Workflow))
+	fmt.Fprintf(&b, "**hookrunner** workflow `%s` %s\n\n", job.Workflow, status(res))
+	fmt.Fprintf(&b, "| Run | Duration | Exit code |\n|---|---|---|\n")
+	fmt.Fprintf(&b, "| `%s` | %.1fs | %d |\n", job.ID, res.Duration().Seconds(), res.ExitCode)
+	if res.Err != nil && res.ExitCode == -1 && !res.TimedOut && !res.Canceled {
+		fmt.Fprintf(&b, "\nError: `%s`\n", strings.ReplaceAll(res.Err.Error(), "`", "'"))
+	}
+	if strings.TrimSpace(tail) != "" {
+		f := fence(tail)
+		fmt.Fprintf(&b, "\n<details><summary>Output (last %d lines)</summary>\n\n%s\n%s\n%s\n\n</details>\n",
+			tailLines, f, strings.TrimRight(tail, "\n"), f)
+	}
+	return b.String()
+}
+
+// fence returns a code fence longer than any run of backticks in s.
+func fence(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// readTail returns the last n lines of the file at path, reading at most
+// maxTailBytes from its end.
+func readTail(path string, n int) (string, error) {
+	if n <= 0 {
+		return "", nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := info.Size() - maxTailBytes
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if offset > 0 && len(lines) > 1 {
+		// The first line is probably cut in half.
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// fromGitHub reports whether job's event came from GitHub, so results can be
+// reported there. Jobs queued before providers were recorded have none.
+func fromGitHub(job *queue.Job) bool {
+	return job.Vars.Provider == "" || job.Vars.Provider == "github"
+}
+
+// apiContext bounds the API calls made for job and authenticates them as the
+// GitHub App installation its event came from, if any.
+func apiContext(job *queue.Job, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(github.WithInstallation(context.Background(), job.Installation), timeout)
+}
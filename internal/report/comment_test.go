@@ -0,0 +1,166 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/github"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/workflow"
+)
+
+type fakeComments struct {
+	mu       sync.Mutex
+	comments map[int64]string
+	nextID   int64
+	created  int
+	updated  int
+}
+
+func (f *fakeComments) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/org/repo/issues/42/comments", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		f.nextID++
+		f.comments[f.nextID] = in["body"]
+		f.created++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(github.Comment{ID: f.nextID, Body: in["body"]})
+	})
+	mux.HandleFunc("PATCH /repos/org/repo/issues/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		for id := range f.comments {
+			if r.PathValue("id") == strconv.FormatInt(id, 10) {
+				f.comments[id] = in["body"]
+				f.updated++
+				json.NewEncoder(w).Encode(github.Comment{ID: id, Body: in["body"]})
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("GET /repos/org/repo/issues/42/comments", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var list []github.Comment
+		for id := int64(1); id <= f.nextID; id++ {
+			list = append(list, github.Comment{ID: id, Body: f.comments[id]})
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	return mux
+}
+
+func TestCommenter(t *testing.T) {
+	fake := &fakeComments{comments: make(map[int64]string)}
+	srv := httptest.NewServer(fake.handler())
+	defer srv.Close()
+
+	dir := t.TempDir()
+	logPath := func(id string) string { return filepath.Join(dir, id+".log") }
+	commenter := NewCommenter(github.NewClient(srv.URL, "tok"), logPath)
+
+	exitCode := 0
+	exec := commenter.Wrap(func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+		os.WriteFile(logPath(job.ID), []byte("line1\nline2\nline3\n"), 0600)
+		start := time.Now()
+		return workflow.Result{Start: start, End: start.Add(1500 * time.Millisecond), ExitCode: exitCode}
+	})
+
+	job := &queue.Job{
+		ID:       "run1",
+		Workflow: "review",
+		Vars:     workflow.TemplateVars{RepoFullName: "org/repo", PRNumber: "42"},
+	}
+	wf := config.WorkflowConfig{Report: config.ReportConfig{Comment: true, Update: true, TailLines: 2}}
+
+	t.Run("posts result comment", func(t *testing.T) {
+		exec(context.Background(), job, wf)
+		if fake.created != 1 {
+			t.Fatalf("expected 1 comment, got %d", fake.created)
+		}
+		body := fake.comments[1]
+		for _, want := range []string{"`review` ✅ succeeded", "1.5s", "line2\nline3", "<!-- hookrunner:workflow=review -->"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("comment missing %q:\n%s", want, body)
+			}
+		}
+		if strings.Contains(body, "line1") {
+			t.Errorf("comment should only contain the last 2 lines:\n%s", body)
+		}
+	})
+
+	t.Run("updates existing comment", func(t *testing.T) {
+		exitCode = 2
+		job.ID = "run2"
+		exec(context.Background(), job, wf)
+		if fake.created != 1 || fake.updated != 1 {
+			t.Fatalf("created = %d, updated = %d", fake.created, fake.updated)
+		}
+		if !strings.Contains(fake.comments[1], "❌ failed") {
+			t.Errorf("comment not updated:\n%s", fake.comments[1])
+		}
+	})
+
+	t.Run("skips workflows without report", func(t *testing.T) {
+		exec(context.Background(), job, config.WorkflowConfig{})
+		if fake.created != 1 || fake.updated != 1 {
+			t.Errorf("created = %d, updated = %d", fake.created, fake.updated)
+		}
+	})
+
+	t.Run("skips canceled runs", func(t *testing.T) {
+		canceled := commenter.Wrap(func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+			return workflow.Result{ExitCode: -1, Canceled: true}
+		})
+		canceled(context.Background(), &queue.Job{ID: "run4", Workflow: "review", Vars: job.Vars}, wf)
+		if fake.created != 1 || fake.updated != 1 {
+			t.Errorf("created = %d, updated = %d", fake.created, fake.updated)
+		}
+	})
+
+	t.Run("skips other providers", func(t *testing.T) {
+		gitlab := &queue.Job{ID: "run3", Workflow: "review",
+			Vars: workflow.TemplateVars{RepoFullName: "group/project", PRNumber: "1", Provider: "gitlab"}}
+		exec(context.Background(), gitlab, wf)
+		if fake.created != 1 || fake.updated != 1 {
+			t.Errorf("created = %d, updated = %d", fake.created, fake.updated)
+		}
+	})
+}
+
+func TestReadTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.log")
+	os.WriteFile(path, []byte("a\nb\nc\n"), 0600)
+	for n, want := range map[int]string{2: "b\nc", 5: "a\nb\nc", 0: "", -1: ""} {
+		got, err := readTail(path, n)
+		if err != nil || got != want {
+			t.Errorf("readTail(%d) = %q, %v; want %q", n, got, err, want)
+		}
+	}
+}
+
+func TestFence(t *testing.T) {
+	if got := fence("plain"); got != "```" {
+		t.Errorf("fence = %q", got)
+	}
+	if got := fence("has ```` inside"); got != "`````" {
+		t.Errorf("fence = %q", got)
+	}
+}
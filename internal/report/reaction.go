@@ -0,0 +1,169 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/github"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/workflow"
+)
+
+// Reactor acknowledges triggering comments for workflows with
+// report.reactions set: 👀 when a job is queued, swapped for 🚀 on success or
+// 😕 on failure once it finishes.
+type Reactor struct {
+	client *github.Client
+
+	mu   sync.Mutex
+	acks map[string]*ack // by job ID
+}
+
+type ack struct {
+	subject github.ReactionSubject
+	done    chan struct{}
+	eyesID  int64
+}
+
+func NewReactor(client *github.Client) *Reactor {
+	return &Reactor{client: client, acks: make(map[string]*ack)}
+}
+
+// Queued is a queue.SubmitHook that adds the 👀 reaction in the background.
+func (r *Reactor) Queued(job *queue.Job, wf config.WorkflowConfig) {
+	if !wf.Report.Reactions || !fromGitHub(job) {
+		return
+	}
+	subject, ok := reactionSubject(job)
+	if !ok {
+		return
+	}
+
+	a := &ack{subject: subject, done: make(chan struct{})}
+	r.mu.Lock()
+	r.acks[job.ID] = a
+	r.mu.Unlock()
+
+	go func() {
+		defer close(a.done)
+		ctx, cancel := apiContext(job, 30*time.Second)
+		defer cancel()
+		reaction, err := r.client.AddReaction(ctx, subject, "eyes")
+		if err != nil {
+			log.Printf("Report: failed to add reaction for job %s: %v", job.ID, err)
+			return
+		}
+		a.eyesID = reaction.ID
+	}()
+}
+
+// Dropped is a queue.DropHook for jobs superseded before they started.
+func (r *Reactor) Dropped(job *queue.Job, wf config.WorkflowConfig) {
+	if wf.Report.Reactions && fromGitHub(job) {
+		go r.finish(job, workflow.Result{Canceled: true}, nil)
+	}
+}
+
+func (r *Reactor) Wrap(next queue.ExecFunc) queue.ExecFunc {
+	return func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+		res := next(ctx, job, wf)
+		if wf.Report.Reactions && fromGitHub(job) {
+			r.finish(job, res, context.Cause(ctx))
+		}
+		return res
+	}
+}
+
+// finish resolves job's 👀 reaction. cause is why a canceled job's context
+// was canceled.
+func (r *Reactor) finish(job *queue.Job, res workflow.Result, cause error) {
+	r.mu.Lock()
+	a := r.acks[job.ID]
+	delete(r.acks, job.ID)
+	// Jobs for the same comment share one 👀 reaction, so only the last one
+	// to finish removes it.
+	shared := false
+	for _, other := range r.acks {
+		if a != nil && other.subject == a.subject {
+			shared = true
+		}
+	}
+	r.mu.Unlock()
+	if a != nil {
+		<-a.done
+	}
+
+	// A job interrupted by shutdown runs again after a restart and keeps its
+	// 👀 until then.
+	if res.Canceled && errors.Is(cause, queue.ErrShuttingDown) {
+		return
+	}
+
+	subject, ok := reactionSubject(job)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := apiContext(job, 30*time.Second)
+	defer cancel()
+
+	if !shared {
+		var eyesID int64
+		if a != nil {
+			eyesID = a.eyesID
+		} else {
+			// Restored after a restart: adding the reaction again returns the
+			// existing one, which gives us its ID.
+			if reaction, err := r.client.AddReaction(ctx, subject, "eyes"); err == nil {
+				eyesID = reaction.ID
+			}
+		}
+		if eyesID != 0 {
+			if err := r.client.DeleteReaction(ctx, subject, eyesID); err != nil {
+				log.Printf("Report: failed to remove reaction for job %s: %v", job.ID, err)
+			}
+		}
+	}
+
+	// A superseded job has no result; the job that replaced it reacts to
+	// its own comment.
+	if res.Canceled {
+		return
+	}
+
+	content := "rocket"
+	if res.TimedOut || res.Err != nil || res.ExitCode != 0 {
+		content = "confused"
+	}
+	if _, err := r.client.AddReaction(ctx, subject, content); err != nil {
+		log.Printf("Report: failed to add reaction for job %s: %v", job.ID, err)
+	}
+}
+
+// reactionSubject picks what to react to for job. Reviews cannot carry
+// reactions, so for those the PR itself gets them.
+func reactionSubject(job *queue.Job) (github.ReactionSubject, bool) {
+	subject := github.ReactionSubject{Repo: job.Vars.RepoFullName}
+	var id string
+	switch job.Vars.EventType {
+	case "issue_comment", "pull_request_review_comment":
+		subject.Kind = job.Vars.EventType
+		id = job.Vars.CommentID
+	case "pull_request_review":
+		subject.Kind = "issue"
+		id = job.Vars.PRNumber
+	default:
+		return subject, false
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n == 0 || subject.Repo == "" {
+		return subject, false
+	}
+	subject.ID = n
+	return subject, true
+}
@@ -0,0 +1,181 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/github"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/workflow"
+)
+
+type fakeReactions struct {
+	mu     sync.Mutex
+	calls  []string
+	nextID int64
+}
+
+func (f *fakeReactions) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.Method == http.MethodDelete {
+			f.calls = append(f.calls, "DELETE "+r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		f.calls = append(f.calls, "POST "+r.URL.Path+" "+in["content"])
+		f.nextID++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(github.Reaction{ID: f.nextID, Content: in["content"]})
+	})
+}
+
+func (f *fakeReactions) snapshot() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func TestReactor(t *testing.T) {
+	tests := []struct {
+		name     string
+		vars     workflow.TemplateVars
+		exitCode int
+		want     []string
+	}{
+		{
+			name:     "issue comment success",
+			vars:     workflow.TemplateVars{RepoFullName: "org/repo", PRNumber: "42", CommentID: "99", EventType: "issue_comment"},
+			exitCode: 0,
+			want: []string{
+				"POST /repos/org/repo/issues/comments/99/reactions eyes",
+				"DELETE /repos/org/repo/issues/comments/99/reactions/1",
+				"POST /repos/org/repo/issues/comments/99/reactions rocket",
+			},
+		},
+		{
+			name:     "review comment failure",
+			vars:     workflow.TemplateVars{RepoFullName: "org/repo", PRNumber: "42", CommentID: "5", EventType: "pull_request_review_comment"},
+			exitCode: 1,
+			want: []string{
+				"POST /repos/org/repo/pulls/comments/5/reactions eyes",
+				"DELETE /repos/org/repo/pulls/comments/5/reactions/1",
+				"POST /repos/org/repo/pulls/comments/5/reactions confused",
+			},
+		},
+		{
+			name:     "review reacts on PR",
+			vars:     workflow.TemplateVars{RepoFullName: "org/repo", PRNumber: "42", CommentID: "5", EventType: "pull_request_review"},
+			exitCode: 0,
+			want: []string{
+				"POST /repos/org/repo/issues/42/reactions eyes",
+				"DELETE /repos/org/repo/issues/42/reactions/1",
+				"POST /repos/org/repo/issues/42/reactions rocket",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeReactions{}
+			srv := httptest.NewServer(fake.handler())
+			defer srv.Close()
+
+			cfg := &config.Config{
+				Workflows: map[string]config.WorkflowConfig{
+					"review": {Trigger: "x", Command: "true", Report: config.ReportConfig{Reactions: true}},
+				},
+			}
+			reactor := NewReactor(github.NewClient(srv.URL, "tok"))
+			exitCode := tt.exitCode
+			q := queue.New(cfg, reactor.Wrap(func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+				return workflow.Result{ExitCode: exitCode}
+			}))
+			q.OnSubmit(reactor.Queued)
+
+			if err := q.Submit(&queue.Job{Workflow: "review", Vars: tt.vars}); err != nil {
+				t.Fatal(err)
+			}
+			q.Wait()
+
+			got := fake.snapshot()
+			if len(got) != len(tt.want) {
+				t.Fatalf("calls = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("call %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		fake := &fakeReactions{}
+		srv := httptest.NewServer(fake.handler())
+		defer srv.Close()
+
+		reactor := NewReactor(github.NewClient(srv.URL, "tok"))
+		job := &queue.Job{ID: "j", Workflow: "review", Vars: workflow.TemplateVars{
+			RepoFullName: "org/repo", CommentID: "99", EventType: "issue_comment",
+		}}
+		reactor.Queued(job, config.WorkflowConfig{})
+		reactor.Wrap(func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+			return workflow.Result{}
+		})(context.Background(), job, config.WorkflowConfig{})
+
+		if got := fake.snapshot(); len(got) != 0 {
+			t.Errorf("expected no API calls, got %v", got)
+		}
+	})
+	canceled := map[string]struct {
+		cause error
+		want  []string
+	}{
+		"superseded": {fmt.Errorf("superseded by job k"), []string{
+			"POST /repos/org/repo/issues/comments/99/reactions eyes",
+			"DELETE /repos/org/repo/issues/comments/99/reactions/1",
+		}},
+		"shutdown": {queue.ErrShuttingDown, []string{
+			"POST /repos/org/repo/issues/comments/99/reactions eyes",
+		}},
+	}
+	for name, tt := range canceled {
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeReactions{}
+			srv := httptest.NewServer(fake.handler())
+			defer srv.Close()
+
+			reactor := NewReactor(github.NewClient(srv.URL, "tok"))
+			wf := config.WorkflowConfig{Report: config.ReportConfig{Reactions: true}}
+			job := &queue.Job{ID: "j", Workflow: "review", Vars: workflow.TemplateVars{
+				RepoFullName: "org/repo", CommentID: "99", EventType: "issue_comment",
+			}}
+			reactor.Queued(job, wf)
+			ctx, cancel := context.WithCancelCause(context.Background())
+			cancel(tt.cause)
+			reactor.Wrap(func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+				return workflow.Result{ExitCode: -1, Canceled: true}
+			})(ctx, job, wf)
+
+			got := fake.snapshot()
+			if len(got) != len(tt.want) {
+				t.Fatalf("calls = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("call %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
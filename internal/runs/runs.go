@@ -0,0 +1,258 @@
+package runs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/workflow"
+)
+
+type Run struct {
+	ID              string    `json:"id"`
+	Workflow        string    `json:"workflow"`
+	Repo            string    `json:"repo"`
+	PRNumber        string    `json:"pr_number"`
+	Author          string    `json:"author"`
+	EventType       string    `json:"event_type"`
+	Delivery        string    `json:"delivery,omitempty"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end,omitempty"`
+	ExitCode        int       `json:"exit_code"`
+	TimedOut        bool      `json:"timed_out"`
+	Canceled        bool      `json:"canceled"`
+	Error           string    `json:"error,omitempty"`
+	OutputPath      string    `json:"output_path,omitempty"`
+	OutputBytes     int64     `json:"output_bytes"`
+	OutputTruncated bool      `json:"output_truncated,omitempty"`
+}
+
+func (r *Run) Status() string {
+	switch {
+	case r.End.IsZero():
+		return "running"
+	case r.TimedOut:
+		return "timed_out"
+	case r.Canceled:
+		return "canceled"
+	case r.ExitCode != 0 || r.Error != "":
+		return "failed"
+	default:
+		return "success"
+	}
+}
+
+// Store keeps one JSON record per run, alongside its output log, in dir.
+type Store struct {
+	dir         string
+	maxLogBytes int64
+	maxAge      time.Duration
+	maxRuns     int
+	pruneMu     sync.Mutex
+}
+
+// Open returns the store in dir. Values in rc below 1, such as the -1 that
+// means "no limit" in the config, disable the corresponding log size cap or
+// retention limit.
+func Open(dir string, rc config.RunsConfig) *Store {
+	return &Store{
+		dir:         dir,
+		maxLogBytes: int64(rc.MaxLogMB) << 20,
+		maxAge:      time.Duration(rc.RetentionDays) * 24 * time.Hour,
+		maxRuns:     rc.MaxRuns,
+	}
+}
+
+func (s *Store) LogPath(id string) string {
+	return filepath.Join(s.dir, id+".log")
+}
+
+func (s *Store) Save(r *Run) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding run: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("creating runs dir: %w", err)
+	}
+	path := filepath.Join(s.dir, r.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing run: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get returns the run with the given ID or unique ID prefix.
+func (s *Store) Get(id string) (*Run, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, id+"*.json"))
+	if err != nil {
+		return nil, err
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("run %s not found", id)
+	case 1:
+		return readRun(matches[0])
+	default:
+		return nil, fmt.Errorf("run ID prefix %s is ambiguous", id)
+	}
+}
+
+// List returns all recorded runs, most recent first.
+func (s *Store) List() ([]*Run, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var list []*Run
+	for _, path := range matches {
+		r, err := readRun(path)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Start.After(list[j].Start) })
+	return list, nil
+}
+
+func readRun(path string) (*Run, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading run: %w", err)
+	}
+	var r Run
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+	return &r, nil
+}
+
+// Execute is a queue.ExecFunc that runs the job with its output streamed to
+// the run's log file and records the run before and after it executes.
+func (s *Store) Execute(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+	run := &Run{
+		ID:         job.ID,
+		Workflow:   job.Workflow,
+		Repo:       job.Vars.RepoFullName,
+		PRNumber:   job.Vars.PRNumber,
+		Author:     job.Author,
+		EventType:  job.Vars.EventType,
+		Delivery:   job.Delivery,
+		Start:      time.Now(),
+		OutputPath: s.LogPath(job.ID),
+	}
+	if err := s.Save(run); err != nil {
+		log.Printf("WARNING: Failed to record run %s: %v", run.ID, err)
+	}
+
+	var out io.Writer = io.Discard
+	logFile, err := os.OpenFile(run.OutputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Printf("WARNING: Failed to open output log for run %s: %v", run.ID, err)
+		run.OutputPath = ""
+	} else {
+		out = &limitWriter{f: logFile, max: s.maxLogBytes}
+	}
+
+	res := workflow.Execute(ctx, job.Workflow, wf, job.Vars, out)
+	if logFile != nil {
+		logFile.Close()
+	}
+
+	run.Start = res.Start
+	run.End = res.End
+	run.ExitCode = res.ExitCode
+	run.TimedOut = res.TimedOut
+	run.Canceled = res.Canceled
+	if res.Err != nil {
+		run.Error = res.Err.Error()
+	}
+	if lw, ok := out.(*limitWriter); ok {
+		run.OutputBytes = lw.written
+		run.OutputTruncated = lw.truncated
+	}
+	if err := s.Save(run); err != nil {
+		log.Printf("WARNING: Failed to record run %s: %v", run.ID, err)
+	}
+	log.Printf("Run %s (%q) recorded: %s, %d bytes of output", run.ID, run.Workflow, run.Status(), run.OutputBytes)
+
+	if err := s.Prune(); err != nil {
+		log.Printf("WARNING: Failed to prune run history: %v", err)
+	}
+	return res
+}
+
+// Prune removes finished runs older than the retention period and the oldest
+// finished runs beyond the configured maximum.
+func (s *Store) Prune() error {
+	s.pruneMu.Lock()
+	defer s.pruneMu.Unlock()
+
+	list, err := s.List()
+	if err != nil {
+		return err
+	}
+	kept := 0
+	for _, r := range list {
+		if r.End.IsZero() {
+			continue
+		}
+		expired := s.maxAge > 0 && time.Since(r.End) > s.maxAge
+		if !expired && (s.maxRuns <= 0 || kept < s.maxRuns) {
+			kept++
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, r.ID+".json")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(s.LogPath(r.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// limitWriter writes to f until max bytes have been written, then appends a
+// truncation notice and discards the rest while still counting it.
+type limitWriter struct {
+	f         *os.File
+	max       int64
+	written   int64
+	truncated bool
+}
+
+func (w *limitWriter) Name() string {
+	return w.f.Name()
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	w.written += int64(n)
+	if w.truncated {
+		return n, nil
+	}
+	if w.max > 0 && w.written > w.max {
+		keep := int64(n) - (w.written - w.max)
+		if _, err := w.f.Write(p[:keep]); err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(w.f, "\n[hookrunner: output truncated at %d bytes]\n", w.max)
+		w.truncated = true
+		return n, nil
+	}
+	if _, err := w.f.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,156 @@
+package runs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/workflow"
+)
+
+func TestStore(t *testing.T) {
+	s := Open(t.TempDir(), config.RunsConfig{})
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	for i, id := range []string{"aaa111", "aaa222", "bbb333"} {
+		r := &Run{ID: id, Workflow: "review", Start: base.Add(time.Duration(i) * time.Minute)}
+		if err := s.Save(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0].ID != "bbb333" || list[2].ID != "aaa111" {
+		t.Errorf("expected runs newest first, got %v", list)
+	}
+
+	t.Run("get by prefix", func(t *testing.T) {
+		r, err := s.Get("bbb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.ID != "bbb333" {
+			t.Errorf("got %q", r.ID)
+		}
+	})
+
+	t.Run("ambiguous prefix", func(t *testing.T) {
+		if _, err := s.Get("aaa"); err == nil {
+			t.Error("expected error for ambiguous prefix")
+		}
+	})
+
+	t.Run("unknown id", func(t *testing.T) {
+		if _, err := s.Get("zzz"); err == nil {
+			t.Error("expected error for unknown run")
+		}
+	})
+}
+
+func TestExecute(t *testing.T) {
+	s := Open(t.TempDir(), config.RunsConfig{})
+	job := &queue.Job{
+		ID:       "abc123",
+		Workflow: "review",
+		Delivery: "guid-1",
+		Author:   "alice",
+		Vars: workflow.TemplateVars{
+			RepoFullName: "org/repo",
+			PRNumber:     "412",
+			EventType:    "pull_request",
+		},
+	}
+	wf := config.WorkflowConfig{Command: "echo reviewing {{.PRNumber}}; echo oops >&2; exit 3", Timeout: 5}
+
+	res := s.Execute(context.Background(), job, wf)
+	if res.ExitCode != 3 {
+		t.Errorf("exit code = %d, want 3", res.ExitCode)
+	}
+
+	r, err := s.Get("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Repo != "org/repo" || r.PRNumber != "412" || r.Author != "alice" || r.Delivery != "guid-1" {
+		t.Errorf("unexpected run metadata: %+v", r)
+	}
+	if r.Status() != "failed" || r.ExitCode != 3 {
+		t.Errorf("status = %q, exit code = %d", r.Status(), r.ExitCode)
+	}
+	if r.OutputPath != s.LogPath("abc123") {
+		t.Errorf("output path = %q", r.OutputPath)
+	}
+	data, err := os.ReadFile(r.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "reviewing 412\noops\n" {
+		t.Errorf("output = %q", data)
+	}
+	if r.OutputBytes != int64(len(data)) || r.OutputTruncated {
+		t.Errorf("output bytes = %d, truncated = %t", r.OutputBytes, r.OutputTruncated)
+	}
+}
+
+func TestExecuteTruncatesOutput(t *testing.T) {
+	s := Open(t.TempDir(), config.RunsConfig{MaxLogMB: 1})
+	job := &queue.Job{ID: "big", Workflow: "spam"}
+	wf := config.WorkflowConfig{Command: "head -c 3000000 /dev/zero", Timeout: 5}
+
+	s.Execute(context.Background(), job, wf)
+
+	r, err := s.Get("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OutputTruncated || r.OutputBytes != 3000000 {
+		t.Errorf("output bytes = %d, truncated = %t", r.OutputBytes, r.OutputTruncated)
+	}
+	info, err := os.Stat(r.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 1<<20+100 {
+		t.Errorf("log file is %d bytes, expected it to be capped at 1 MB", info.Size())
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	s := Open(dir, config.RunsConfig{RetentionDays: 7, MaxRuns: 2})
+	now := time.Now()
+
+	runs := []*Run{
+		{ID: "old", Start: now.Add(-10 * 24 * time.Hour), End: now.Add(-10 * 24 * time.Hour)},
+		{ID: "r1", Start: now.Add(-3 * time.Hour), End: now.Add(-3 * time.Hour)},
+		{ID: "r2", Start: now.Add(-2 * time.Hour), End: now.Add(-2 * time.Hour)},
+		{ID: "r3", Start: now.Add(-1 * time.Hour), End: now.Add(-1 * time.Hour)},
+		{ID: "live", Start: now.Add(-20 * 24 * time.Hour)},
+	}
+	for _, r := range runs {
+		s.Save(r)
+		os.WriteFile(s.LogPath(r.ID), []byte("output"), 0600)
+	}
+
+	if err := s.Prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	list, _ := s.List()
+	var ids []string
+	for _, r := range list {
+		ids = append(ids, r.ID)
+	}
+	if len(ids) != 3 || ids[0] != "r3" || ids[1] != "r2" || ids[2] != "live" {
+		t.Errorf("remaining runs = %v, want [r3 r2 live]", ids)
+	}
+	if _, err := os.Stat(s.LogPath("old")); !os.IsNotExist(err) {
+		t.Error("expected log of pruned run to be removed")
+	}
+}
@@ -9,6 +9,7 @@ import (
 )
 
 type Server struct {
+	mux     *http.ServeMux
 	httpSrv *http.Server
 }
 
@@ -18,6 +19,7 @@ func New(port int, webhookHandler http.Handler) *Server {
 	mux.Handle("/webhook", webhookHandler)
 
 	return &Server{
+		mux: mux,
 		httpSrv: &http.Server{
 			Addr:    fmt.Sprintf("127.0.0.1:%d", port),
 			Handler: mux,
@@ -25,6 +27,12 @@ func New(port int, webhookHandler http.Handler) *Server {
 	}
 }
 
+// Handle registers an extra route, such as a webhook source's path. It must
+// be called before Start.
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
 func (s *Server) Start() error {
 	ln, err := net.Listen("tcp", s.httpSrv.Addr)
 	if err != nil {
@@ -0,0 +1,413 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"hookrunner/internal/workflow"
+)
+
+type webhookEvent struct {
+	Action  string `json:"action"`
+	Comment struct {
+		ID                int64  `json:"id"`
+		Body              string `json:"body"`
+		AuthorAssociation string `json:"author_association"`
+		User              struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"comment"`
+	Issue struct {
+		Number      int     `json:"number"`
+		Title       string  `json:"title"`
+		State       string  `json:"state"`
+		Labels      []label `json:"labels"`
+		PullRequest *struct {
+			URL string `json:"url"`
+		} `json:"pull_request"`
+	} `json:"issue"`
+	Discussion struct {
+		Number            int    `json:"number"`
+		Title             string `json:"title"`
+		Body              string `json:"body"`
+		AuthorAssociation string `json:"author_association"`
+		Category          struct {
+			Name string `json:"name"`
+		} `json:"category"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"discussion"`
+	Review struct {
+		ID                int64  `json:"id"`
+		Body              string `json:"body"`
+		AuthorAssociation string `json:"author_association"`
+		User              struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"review"`
+	PullRequest struct {
+		Number int     `json:"number"`
+		Title  string  `json:"title"`
+		Merged bool    `json:"merged"`
+		Labels []label `json:"labels"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		CloneURL string `json:"clone_url"`
+	} `json:"repository"`
+	Installation struct {
+		ID int64 `json:"id"`
+	} `json:"installation"`
+	Sender struct {
+		Login string `json:"login"`
+		Type  string `json:"type"`
+	} `json:"sender"`
+	Label   label `json:"label"`
+	Changes struct {
+		Body *struct {
+			From string `json:"from"`
+		} `json:"body"`
+	} `json:"changes"`
+
+	Release struct {
+		TagName string `json:"tag_name"`
+		Name    string `json:"name"`
+		HTMLURL string `json:"html_url"`
+	} `json:"release"`
+	RefType    string `json:"ref_type"`
+	Deployment struct {
+		ID          int64  `json:"id"`
+		Environment string `json:"environment"`
+		SHA         string `json:"sha"`
+	} `json:"deployment"`
+	DeploymentStatus struct {
+		State       string `json:"state"`
+		Environment string `json:"environment"`
+		TargetURL   string `json:"target_url"`
+	} `json:"deployment_status"`
+
+	WorkflowRun ciRun `json:"workflow_run"`
+	CheckRun    ciRun `json:"check_run"`
+	CheckSuite  ciRun `json:"check_suite"`
+
+	// push
+	Ref     string `json:"ref"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Deleted bool   `json:"deleted"`
+	Pusher  struct {
+		Name string `json:"name"`
+	} `json:"pusher"`
+	Commits []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+}
+
+type label struct {
+	Name string `json:"name"`
+}
+
+// ciRun covers the fields hookrunner uses from workflow runs, check runs and
+// check suites.
+type ciRun struct {
+	Name         string `json:"name"`
+	Conclusion   string `json:"conclusion"`
+	HeadBranch   string `json:"head_branch"`
+	HeadSHA      string `json:"head_sha"`
+	HTMLURL      string `json:"html_url"`
+	PullRequests []struct {
+		Number int `json:"number"`
+	} `json:"pull_requests"`
+	// Check runs carry the branch on their suite, and suites are named after
+	// the app that owns them.
+	CheckSuite *struct {
+		HeadBranch string `json:"head_branch"`
+	} `json:"check_suite"`
+	App struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	} `json:"app"`
+}
+
+// event is a delivery reduced to what workflow matching needs.
+type event struct {
+	Type    string
+	Display string // event name shown in logs
+	Action  string
+	// Match is what workflow triggers are matched against: the comment body
+	// for comment events, a summary string like "closed:merged" otherwise.
+	Match string
+	// Previous is the comment or review text before an edit, or nil when the
+	// edit did not touch it.
+	Previous *string
+	Author   string
+	// Association is the author's author_association with the repository,
+	// e.g. "MEMBER". Only comments, reviews and discussions carry it.
+	Association  string
+	Sender       string
+	SenderIsBot  bool
+	Installation int64
+	// AppID is the GitHub App that owns a check suite.
+	AppID int64
+	Vars  workflow.TemplateVars
+
+	// Labels are the labels on the issue or PR, checked against a workflow's
+	// labels filter. For labeled and unlabeled actions the filter applies to
+	// Vars.Label, the label that was added or removed, instead.
+	Labels []string
+
+	// Branch and Files are set for push events and checked against a
+	// workflow's branches and paths filters. Deleted marks a push that
+	// deleted its ref.
+	Branch  string
+	Files   []string
+	Deleted bool
+}
+
+// parseEvent decodes a GitHub delivery. A nil event with a message means the
+// delivery is valid but nothing can run on it. Actions are not filtered here;
+// each workflow decides which it accepts.
+func parseEvent(eventType string, body []byte) (*event, string, error) {
+	var payload webhookEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, "", err
+	}
+
+	ev := &event{
+		Type:         eventType,
+		Display:      eventType,
+		Action:       payload.Action,
+		Installation: payload.Installation.ID,
+		Sender:       payload.Sender.Login,
+		SenderIsBot:  payload.Sender.Type == "Bot",
+		Vars: workflow.TemplateVars{
+			RepoFullName: payload.Repository.FullName,
+			RepoCloneURL: payload.Repository.CloneURL,
+			EventType:    eventType,
+			Provider:     "github",
+		},
+	}
+
+	switch eventType {
+	case "issue_comment", "pull_request_review_comment":
+		ev.setComment(payload.Comment.ID, payload.Comment.Body, payload.Comment.User.Login)
+		ev.Association = payload.Comment.AuthorAssociation
+		// Show "pr_comment" instead of "issue_comment" for PR comments.
+		if eventType == "issue_comment" && payload.Issue.PullRequest != nil {
+			ev.Display = "pr_comment"
+		}
+	case "discussion_comment", "discussion":
+		if eventType == "discussion_comment" {
+			ev.setComment(payload.Comment.ID, payload.Comment.Body, payload.Comment.User.Login)
+			ev.Association = payload.Comment.AuthorAssociation
+		} else {
+			ev.setComment(0, payload.Discussion.Body, payload.Discussion.User.Login)
+			ev.Association = payload.Discussion.AuthorAssociation
+		}
+		ev.Vars.DiscussionNumber = fmt.Sprintf("%d", payload.Discussion.Number)
+		ev.Vars.DiscussionCategory = payload.Discussion.Category.Name
+		ev.Vars.DiscussionTitle = payload.Discussion.Title
+	case "pull_request_review":
+		ev.setComment(payload.Review.ID, payload.Review.Body, payload.Review.User.Login)
+		ev.Association = payload.Review.AuthorAssociation
+	case "pull_request":
+		merged := "unmerged"
+		if payload.PullRequest.Merged {
+			merged = "merged"
+		}
+		ev.Match = payload.Action + ":" + merged
+		ev.Author = payload.Sender.Login
+		ev.Vars.HeadSHA = payload.PullRequest.Head.SHA
+		ev.addChangedLabel(&payload)
+	case "issues":
+		ev.Match = payload.Action + ":" + payload.Issue.State
+		ev.Author = payload.Sender.Login
+		ev.addChangedLabel(&payload)
+	case "workflow_run", "check_run", "check_suite":
+		run := payload.WorkflowRun
+		switch eventType {
+		case "check_run":
+			run = payload.CheckRun
+			// Runs reported by hookrunner itself must not trigger workflows,
+			// or a workflow on check_run with report.check would loop.
+			if strings.HasPrefix(run.Name, "hookrunner/") {
+				return nil, "event ignored", nil
+			}
+			if run.CheckSuite != nil {
+				run.HeadBranch = run.CheckSuite.HeadBranch
+			}
+		case "check_suite":
+			run = payload.CheckSuite
+			run.Name = run.App.Name
+			ev.AppID = run.App.ID
+		}
+		ev.Match = payload.Action
+		if run.Conclusion != "" {
+			ev.Match += ":" + run.Conclusion
+		}
+		ev.Author = payload.Sender.Login
+		ev.Vars.RunName = run.Name
+		ev.Vars.Conclusion = run.Conclusion
+		ev.Vars.HeadBranch = run.HeadBranch
+		ev.Vars.HeadSHA = run.HeadSHA
+		ev.Vars.HTMLURL = run.HTMLURL
+		if len(run.PullRequests) > 0 {
+			ev.Vars.PRNumber = fmt.Sprintf("%d", run.PullRequests[0].Number)
+		}
+	case "release":
+		ev.Match = payload.Action + ":" + payload.Release.TagName
+		ev.Author = payload.Sender.Login
+		ev.Vars.TagName = payload.Release.TagName
+		ev.Vars.ReleaseName = payload.Release.Name
+		ev.Vars.HTMLURL = payload.Release.HTMLURL
+	case "create", "delete":
+		// Ref is the bare tag or branch name here, not a full ref.
+		ev.Match = payload.RefType + ":" + payload.Ref
+		ev.Author = payload.Sender.Login
+		if payload.RefType == "tag" {
+			ev.Vars.TagName = payload.Ref
+		}
+	case "deployment":
+		ev.Match = payload.Action + ":" + payload.Deployment.Environment
+		ev.Author = payload.Sender.Login
+		ev.setDeployment(&payload)
+	case "deployment_status":
+		ev.Match = payload.DeploymentStatus.State + ":" + payload.Deployment.Environment
+		ev.Author = payload.Sender.Login
+		ev.setDeployment(&payload)
+		ev.Vars.HTMLURL = payload.DeploymentStatus.TargetURL
+	case "push":
+		ev.Match = payload.Ref
+		ev.Author = payload.Sender.Login
+		if ev.Author == "" {
+			ev.Author = payload.Pusher.Name
+		}
+		if strings.HasPrefix(payload.Ref, "refs/heads/") {
+			ev.Branch = strings.TrimPrefix(payload.Ref, "refs/heads/")
+		}
+		ev.Files = changedFiles(&payload)
+		ev.Deleted = payload.Deleted
+		ev.Vars.Ref = payload.Ref
+		ev.Vars.BeforeSHA = payload.Before
+		ev.Vars.AfterSHA = payload.After
+		ev.Vars.Pusher = payload.Pusher.Name
+		if !payload.Deleted {
+			ev.Vars.HeadSHA = payload.After
+		}
+	default:
+		return nil, "event ignored", nil
+	}
+
+	if payload.Changes.Body != nil && bodyEvents[eventType] {
+		ev.Previous = &payload.Changes.Body.From
+	}
+
+	number, title, labels := payload.PullRequest.Number, payload.PullRequest.Title, payload.PullRequest.Labels
+	if number == 0 {
+		number, title, labels = payload.Issue.Number, payload.Issue.Title, payload.Issue.Labels
+	}
+	if number != 0 && ev.Vars.PRNumber == "" {
+		ev.Vars.PRNumber = fmt.Sprintf("%d", number)
+	}
+	ev.Vars.IssueTitle = title
+	ev.Labels = labelNames(labels)
+	return ev, "", nil
+}
+
+// bodyEvents are the events whose match string is a comment, review or
+// discussion body. Only their edits are checked against the previous body;
+// for other events changes.body is a PR or issue description.
+var bodyEvents = map[string]bool{
+	"issue_comment":               true,
+	"pull_request_review_comment": true,
+	"pull_request_review":         true,
+	"discussion_comment":          true,
+	"discussion":                  true,
+}
+
+// setComment makes a comment or review the trigger text of ev.
+func (ev *event) setComment(id int64, body, author string) {
+	ev.Match = body
+	ev.Author = author
+	ev.Vars.CommentBody = body
+	ev.Vars.CommentAuthor = author
+	if id != 0 {
+		ev.Vars.CommentID = fmt.Sprintf("%d", id)
+	}
+}
+
+// fromBot reports whether a bot sent the event or wrote its comment.
+func (ev *event) fromBot() bool {
+	return ev.SenderIsBot || strings.HasSuffix(ev.Sender, "[bot]") || strings.HasSuffix(ev.Author, "[bot]")
+}
+
+// subject names what the event is about for logging, e.g. "org/repo#42" or
+// "org/repo@refs/heads/main".
+func (ev *event) subject() string {
+	v := ev.Vars
+	switch {
+	case v.PRNumber != "":
+		return v.RepoFullName + "#" + v.PRNumber
+	case v.DiscussionNumber != "":
+		return v.RepoFullName + " discussion #" + v.DiscussionNumber
+	case v.Ref != "":
+		return v.RepoFullName + "@" + v.Ref
+	case v.TagName != "":
+		return v.RepoFullName + "@" + v.TagName
+	case v.HeadBranch != "":
+		return v.RepoFullName + "@" + v.HeadBranch
+	case v.Environment != "":
+		return v.RepoFullName + " (" + v.Environment + ")"
+	}
+	return v.RepoFullName
+}
+
+func (ev *event) setDeployment(payload *webhookEvent) {
+	ev.Vars.Environment = payload.Deployment.Environment
+	ev.Vars.HeadSHA = payload.Deployment.SHA
+	if payload.Deployment.ID != 0 {
+		ev.Vars.DeploymentID = fmt.Sprintf("%d", payload.Deployment.ID)
+	}
+}
+
+// addChangedLabel appends the label added or removed by a labeled or
+// unlabeled action to the match string, as in "labeled:open:needs-repro".
+func (ev *event) addChangedLabel(payload *webhookEvent) {
+	if ev.Action == "labeled" || ev.Action == "unlabeled" {
+		ev.Vars.Label = payload.Label.Name
+		ev.Match += ":" + payload.Label.Name
+	}
+}
+
+func labelNames(labels []label) []string {
+	var names []string
+	for _, l := range labels {
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+// changedFiles lists every file added, modified or removed by a push's
+// commits, without duplicates.
+func changedFiles(payload *webhookEvent) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, c := range payload.Commits {
+		for _, list := range [][]string{c.Added, c.Modified, c.Removed} {
+			for _, f := range list {
+				if !seen[f] {
+					seen[f] = true
+					files = append(files, f)
+				}
+			}
+		}
+	}
+	return files
+}
@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+
+	"hookrunner/internal/config"
+)
+
+// giteaProvider handles Gitea and Forgejo, whose payloads follow GitHub's
+// closely enough to share parseEvent. Forgejo sends both its own and Gitea's
+// headers.
+var giteaProvider = provider{
+	name:            "gitea",
+	eventHeaders:    []string{"X-Forgejo-Event", "X-Gitea-Event"},
+	deliveryHeaders: []string{"X-Forgejo-Delivery", "X-Gitea-Delivery"},
+	verify: func(cfg *config.Config, header http.Header, body []byte) bool {
+		sig := firstHeader(header, []string{"X-Forgejo-Signature", "X-Gitea-Signature"})
+		return sig != "" && VerifySignature(body, "sha256="+sig, cfg.Gitea.WebhookSecret)
+	},
+	parse: parseGiteaEvent,
+}
+
+// giteaActions maps Gitea pull request actions that differ from GitHub's.
+// Gitea does not say which label changed.
+var giteaActions = map[string]string{
+	"synchronized":  "synchronize",
+	"label_updated": "labeled",
+	"label_cleared": "unlabeled",
+}
+
+// parseGiteaEvent decodes a Gitea or Forgejo delivery. Only issue comments
+// (including comments on pull requests) and pull request events are
+// supported.
+func parseGiteaEvent(eventType string, body []byte) (*event, string, error) {
+	if eventType != "issue_comment" && eventType != "pull_request" {
+		return nil, "event ignored", nil
+	}
+	ev, ignored, err := parseEvent(eventType, body)
+	if ev == nil {
+		return nil, ignored, err
+	}
+	ev.Vars.Provider = "gitea"
+	if a, ok := giteaActions[ev.Action]; ok {
+		// The pull_request match string starts with the action.
+		ev.Match = a + strings.TrimPrefix(ev.Match, ev.Action)
+		ev.Action = a
+	}
+	return ev, "", nil
+}
@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/workflow"
+)
+
+var gitlabProvider = provider{
+	name:            "gitlab",
+	eventHeaders:    []string{"X-Gitlab-Event"},
+	deliveryHeaders: []string{"X-Gitlab-Event-UUID"},
+	verify: func(cfg *config.Config, header http.Header, body []byte) bool {
+		return VerifyGitLabToken(header.Get("X-Gitlab-Token"), cfg.GitLab.WebhookToken)
+	},
+	parse: parseGitLabEvent,
+}
+
+// VerifyGitLabToken compares the X-Gitlab-Token header with the configured
+// secret token in constant time.
+func VerifyGitLabToken(token, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+type gitlabEvent struct {
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		GitHTTPURL        string `json:"git_http_url"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		ID           int64  `json:"id"`
+		IID          int    `json:"iid"`
+		Title        string `json:"title"`
+		State        string `json:"state"`
+		Action       string `json:"action"`
+		URL          string `json:"url"`
+		Note         string `json:"note"`
+		NoteableType string `json:"noteable_type"`
+		SourceBranch string `json:"source_branch"`
+		OldRev       string `json:"oldrev"`
+		LastCommit   struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+	MergeRequest *gitlabIssue  `json:"merge_request"`
+	Issue        *gitlabIssue  `json:"issue"`
+	Labels       []gitlabLabel `json:"labels"`
+}
+
+// gitlabIssue covers the merge request or issue a note was made on.
+type gitlabIssue struct {
+	IID          int           `json:"iid"`
+	Title        string        `json:"title"`
+	SourceBranch string        `json:"source_branch"`
+	Labels       []gitlabLabel `json:"labels"`
+	LastCommit   struct {
+		ID string `json:"id"`
+	} `json:"last_commit"`
+}
+
+type gitlabLabel struct {
+	Title string `json:"title"`
+}
+
+// gitlabMRActions maps merge request actions onto the GitHub pull_request
+// actions workflows filter on. "update" becomes "synchronize" when it pushed
+// new commits and "edited" otherwise.
+var gitlabMRActions = map[string]string{
+	"open":   "opened",
+	"reopen": "reopened",
+	"close":  "closed",
+	"merge":  "closed",
+}
+
+// parseGitLabEvent decodes a GitLab delivery. Note Hooks on merge requests
+// and issues become issue_comment events, and Merge Request Hooks become
+// pull_request events, with the MR or issue IID as the PR number.
+func parseGitLabEvent(eventType string, body []byte) (*event, string, error) {
+	var payload gitlabEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, "", err
+	}
+
+	ev := &event{
+		Sender: payload.User.Username,
+		Vars: workflow.TemplateVars{
+			RepoFullName: payload.Project.PathWithNamespace,
+			RepoCloneURL: payload.Project.GitHTTPURL,
+			Provider:     "gitlab",
+			HTMLURL:      payload.ObjectAttributes.URL,
+		},
+	}
+	attrs := payload.ObjectAttributes
+
+	switch eventType {
+	case "Note Hook":
+		var target *gitlabIssue
+		switch attrs.NoteableType {
+		case "MergeRequest":
+			target, ev.Display = payload.MergeRequest, "mr_comment"
+		case "Issue":
+			target, ev.Display = payload.Issue, "issue_comment"
+		}
+		if target == nil {
+			return nil, "event ignored", nil
+		}
+		ev.Type = "issue_comment"
+		// Older GitLab versions send no action; edits carry no previous
+		// text, so they never make a trigger newly match.
+		ev.Action = "created"
+		if attrs.Action == "update" {
+			ev.Action = "edited"
+		}
+		ev.setComment(attrs.ID, attrs.Note, payload.User.Username)
+		ev.Vars.PRNumber = fmt.Sprintf("%d", target.IID)
+		ev.Vars.IssueTitle = target.Title
+		ev.Vars.HeadSHA = target.LastCommit.ID
+		ev.Vars.HeadBranch = target.SourceBranch
+		ev.Labels = gitlabLabelNames(target.Labels)
+	case "Merge Request Hook":
+		ev.Type, ev.Display = "pull_request", "merge_request"
+		ev.Action = attrs.Action
+		if a, ok := gitlabMRActions[attrs.Action]; ok {
+			ev.Action = a
+		} else if attrs.Action == "update" {
+			ev.Action = "edited"
+			if attrs.OldRev != "" {
+				ev.Action = "synchronize"
+			}
+		}
+		merged := "unmerged"
+		if attrs.State == "merged" {
+			merged = "merged"
+		}
+		ev.Match = ev.Action + ":" + merged
+		ev.Author = payload.User.Username
+		ev.Vars.PRNumber = fmt.Sprintf("%d", attrs.IID)
+		ev.Vars.IssueTitle = attrs.Title
+		ev.Vars.HeadSHA = attrs.LastCommit.ID
+		ev.Vars.HeadBranch = attrs.SourceBranch
+		ev.Labels = gitlabLabelNames(payload.Labels)
+	default:
+		return nil, "event ignored", nil
+	}
+
+	ev.Vars.EventType = ev.Type
+	return ev, "", nil
+}
+
+func gitlabLabelNames(labels []gitlabLabel) []string {
+	var names []string
+	for _, l := range labels {
+		names = append(names, l.Title)
+	}
+	return names
+}
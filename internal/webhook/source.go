@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"hookrunner/internal/config"
+	"hookrunner/internal/jsonpath"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/workflow"
+)
+
+// SourceHandler receives JSON webhooks for the source called name and runs
+// the workflows that listen to it. Source deliveries are not deduplicated or
+// journaled.
+func SourceHandler(cfg *config.Config, name string, opts Options) http.HandlerFunc {
+	src := cfg.Sources[name]
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10<<20))
+		if err != nil {
+			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if !authenticate(src, r.Header, body) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+
+		res, err := processSource(cfg, opts, name, r.Header, body)
+		if err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(res.status)
+		w.Write([]byte(res.message + "\n"))
+	}
+}
+
+// authenticate checks a source delivery against the source's auth method.
+// An hmac signature may be sent with or without a "sha256=" prefix, and a
+// token with or without "Bearer ".
+func authenticate(src config.SourceConfig, header http.Header, body []byte) bool {
+	switch src.Auth {
+	case "hmac":
+		sig := header.Get(src.Header)
+		if !strings.HasPrefix(sig, "sha256=") {
+			sig = "sha256=" + sig
+		}
+		return VerifySignature(body, sig, src.Secret)
+	case "token":
+		token := strings.TrimPrefix(header.Get(src.Header), "Bearer ")
+		return src.Secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(src.Secret)) == 1
+	case "none":
+		return true
+	}
+	return false
+}
+
+func processSource(cfg *config.Config, opts Options, name string, header http.Header, body []byte) (result, error) {
+	src := cfg.Sources[name]
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return result{}, err
+	}
+
+	eventType := header.Get(src.EventHeader)
+	if src.EventPath != "" {
+		eventType = lookup(doc, src.EventPath)
+	}
+	fields := make(map[string]string, len(src.Fields))
+	for field, p := range src.Fields {
+		fields[field] = lookup(doc, p)
+	}
+
+	log.Printf("════════════════════════════════════════")
+	log.Printf("EVENT: %s from source %q", eventType, name)
+
+	var matched []string
+	for wfName, wf := range cfg.Workflows {
+		if wf.Source != name {
+			continue
+		}
+		if len(wf.Events) > 0 && !contains(wf.Events, eventType) {
+			continue
+		}
+		if !fieldsMatch(wfName, wf.Fields, fields) {
+			continue
+		}
+		vars := workflow.TemplateVars{EventType: eventType, Fields: fields}
+		if wf.Trigger != "" {
+			re, err := regexp.Compile(wf.Trigger)
+			if err != nil {
+				log.Printf("Invalid trigger regex for workflow %q: %v", wfName, err)
+				continue
+			}
+			if !re.MatchString(eventType) {
+				continue
+			}
+			vars.Match = captures(re, eventType)
+		}
+		log.Printf("Matched workflow: %q", wfName)
+		job := &queue.Job{Workflow: wfName, Vars: vars}
+		if err := opts.Queue.Submit(job); err != nil {
+			log.Printf("Workflow %q: failed to enqueue: %v", wfName, err)
+			continue
+		}
+		matched = append(matched, wfName)
+		log.Printf("Queued workflow %q as job %s", wfName, job.ID)
+	}
+
+	if len(matched) > 0 {
+		return result{status: http.StatusAccepted, message: "workflow dispatched", workflows: matched}, nil
+	}
+	log.Printf("No matching workflow")
+	log.Printf("════════════════════════════════════════")
+	return result{status: http.StatusOK, message: "no matching workflow"}, nil
+}
+
+// lookup returns the value at the JSON path p in doc, or "" if there is
+// none. Paths are validated when the config is loaded.
+func lookup(doc interface{}, p string) string {
+	path, err := jsonpath.Parse(p)
+	if err != nil {
+		return ""
+	}
+	v, _ := path.Get(doc)
+	return jsonpath.String(v)
+}
+
+// fieldsMatch reports whether every field filter's regex matches the
+// extracted field. A missing field is matched as "".
+func fieldsMatch(name string, filters, fields map[string]string) bool {
+	for field, pattern := range filters {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("Invalid %s field regex for workflow %q: %v", field, name, err)
+			return false
+		}
+		if !re.MatchString(fields[field]) {
+			return false
+		}
+	}
+	return true
+}
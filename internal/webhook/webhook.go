@@ -1,52 +1,128 @@
 package webhook
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
+	"hookrunner/internal/command"
 	"hookrunner/internal/config"
-	"hookrunner/internal/workflow"
+	"hookrunner/internal/dedup"
+	"hookrunner/internal/github"
+	"hookrunner/internal/journal"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/report"
 )
 
-type webhookEvent struct {
-	Action  string `json:"action"`
-	Comment struct {
-		Body string `json:"body"`
-		User struct {
-			Login string `json:"login"`
-		} `json:"user"`
-	} `json:"comment"`
-	Issue struct {
-		Number      int `json:"number"`
-		PullRequest *struct {
-			URL string `json:"url"`
-		} `json:"pull_request"`
-	} `json:"issue"`
-	Review struct {
-		Body string `json:"body"`
-		User struct {
-			Login string `json:"login"`
-		} `json:"user"`
-	} `json:"review"`
-	PullRequest struct {
-		Number int  `json:"number"`
-		Merged bool `json:"merged"`
-	} `json:"pull_request"`
-	Repository struct {
-		FullName string `json:"full_name"`
-		CloneURL string `json:"clone_url"`
-	} `json:"repository"`
-}
-
-func Handler(cfg *config.Config) http.HandlerFunc {
+type Options struct {
+	Queue *queue.Queue
+	// Deliveries records processed X-GitHub-Delivery IDs. Nil disables
+	// duplicate detection.
+	Deliveries *dedup.Store
+	// Journal receives every accepted delivery. Nil disables journaling.
+	Journal *journal.Journal
+	// Teams checks team membership for workflows with a teams list. Nil
+	// makes those workflows match no one.
+	Teams TeamChecker
+}
+
+// TeamChecker reports whether user is an active member of a GitHub team.
+type TeamChecker interface {
+	IsMember(ctx context.Context, org, team, user string) (bool, error)
+}
+
+// provider adapts one forge's webhooks to the shared matching pipeline.
+// The event type and delivery ID are taken from the first of their headers
+// that is set.
+type provider struct {
+	name            string
+	eventHeaders    []string
+	deliveryHeaders []string
+	verify          func(cfg *config.Config, header http.Header, body []byte) bool
+	parse           func(eventType string, body []byte) (*event, string, error)
+}
+
+func (p provider) eventType(header http.Header) string {
+	return firstHeader(header, p.eventHeaders)
+}
+
+func (p provider) deliveryID(header http.Header) string {
+	return firstHeader(header, p.deliveryHeaders)
+}
+
+func firstHeader(header http.Header, names []string) string {
+	for _, name := range names {
+		if v := header.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+var githubProvider = provider{
+	name:            "github",
+	eventHeaders:    []string{"X-GitHub-Event"},
+	deliveryHeaders: []string{"X-GitHub-Delivery"},
+	verify: func(cfg *config.Config, header http.Header, body []byte) bool {
+		return VerifySignature(body, header.Get("X-Hub-Signature-256"), cfg.WebhookSecret)
+	},
+	parse: parseEvent,
+}
+
+// providers are looked up by the name recorded in journal entries.
+var providers = map[string]provider{
+	githubProvider.name: githubProvider,
+	gitlabProvider.name: gitlabProvider,
+	giteaProvider.name:  giteaProvider,
+}
+
+// sniffProvider picks the provider of a delivery to /webhook from its
+// headers. Gitea and Forgejo also send GitHub's event headers, so they are
+// recognized first.
+func sniffProvider(header http.Header) provider {
+	switch {
+	case giteaProvider.eventType(header) != "":
+		return giteaProvider
+	case gitlabProvider.eventType(header) != "":
+		return gitlabProvider
+	}
+	return githubProvider
+}
+
+// result is the outcome of running one delivery through the pipeline.
+type result struct {
+	status    int
+	message   string
+	workflows []string
+}
+
+// Handler receives GitHub webhooks, and GitLab, Gitea and Forgejo webhooks
+// recognized by their headers.
+func Handler(cfg *config.Config, opts Options) http.HandlerFunc {
+	return serve(cfg, opts, sniffProvider)
+}
+
+// GitLabHandler receives GitLab webhooks, authenticated by the
+// X-Gitlab-Token header.
+func GitLabHandler(cfg *config.Config, opts Options) http.HandlerFunc {
+	return serve(cfg, opts, func(http.Header) provider { return gitlabProvider })
+}
+
+// GiteaHandler receives Gitea and Forgejo webhooks, authenticated by the
+// X-Gitea-Signature or X-Forgejo-Signature header.
+func GiteaHandler(cfg *config.Config, opts Options) http.HandlerFunc {
+	return serve(cfg, opts, func(http.Header) provider { return giteaProvider })
+}
+
+func serve(cfg *config.Config, opts Options, pick func(http.Header) provider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -59,127 +135,391 @@ func Handler(cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
-		if !VerifySignature(body, r.Header.Get("X-Hub-Signature-256"), cfg.WebhookSecret) {
+		p := pick(r.Header)
+		if !p.verify(cfg, r.Header, body) {
 			http.Error(w, "invalid signature", http.StatusForbidden)
 			return
 		}
 
-		eventType := r.Header.Get("X-GitHub-Event")
-
-		var event webhookEvent
-		if err := json.Unmarshal(body, &event); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
-			return
-		}
-
-		// Build the string that triggers are matched against.
-		// For comment events: the comment body.
-		// For pull_request events: "closed:merged" or "closed:unmerged", etc.
-		var matchString string
-		var commentBody, commentAuthor string
-		switch eventType {
-		case "issue_comment", "pull_request_review_comment":
-			if event.Action != "created" {
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("action ignored\n"))
-				return
+		deliveryID := p.deliveryID(r.Header)
+		duplicate := false
+		if opts.Deliveries != nil && deliveryID != "" {
+			duplicate, err = opts.Deliveries.Record(deliveryID)
+			if err != nil {
+				log.Printf("WARNING: Failed to record delivery %s: %v", deliveryID, err)
 			}
-			commentBody = event.Comment.Body
-			commentAuthor = event.Comment.User.Login
-		case "pull_request_review":
-			if event.Action != "submitted" {
+			if duplicate && !anyAllowsRedelivery(cfg.Workflows) {
+				log.Printf("Duplicate delivery %s ignored", deliveryID)
 				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("action ignored\n"))
+				w.Write([]byte("duplicate delivery\n"))
 				return
 			}
-			commentBody = event.Review.Body
-			commentAuthor = event.Review.User.Login
-		case "pull_request":
-			merged := "unmerged"
-			if event.PullRequest.Merged {
-				merged = "merged"
-			}
-			matchString = event.Action + ":" + merged
-		default:
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("event ignored\n"))
+		}
+
+		res, err := process(cfg, opts, p, r.Header, body, duplicate)
+		if err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
 			return
 		}
 
-		if matchString == "" {
-			matchString = commentBody
+		if opts.Journal != nil {
+			err := opts.Journal.Append(journal.Entry{
+				Time:       time.Now(),
+				DeliveryID: deliveryID,
+				Provider:   p.name,
+				Event:      p.eventType(r.Header),
+				Headers:    journalHeaders(r.Header),
+				Body:       body,
+				Workflows:  res.workflows,
+			})
+			if err != nil {
+				log.Printf("WARNING: Failed to journal delivery %s: %v", deliveryID, err)
+			}
 		}
 
-		prNumber := event.PullRequest.Number
-		if prNumber == 0 {
-			prNumber = event.Issue.Number
+		w.WriteHeader(res.status)
+		w.Write([]byte(res.message + "\n"))
+	}
+}
+
+// secretHeaders carry credentials rather than signatures over the body, so
+// they are left out of the journal.
+var secretHeaders = []string{"X-Gitlab-Token", "Authorization"}
+
+func journalHeaders(header http.Header) http.Header {
+	h := header.Clone()
+	for _, k := range secretHeaders {
+		h.Del(k)
+	}
+	return h
+}
+
+// Replay feeds a journaled delivery through the same matching pipeline as
+// Handler, without deduplicating or journaling it again. Only opts.Queue and
+// opts.Teams are used. It returns the names of the workflows that were
+// queued.
+func Replay(cfg *config.Config, opts Options, entry *journal.Entry) ([]string, error) {
+	p := githubProvider
+	if entry.Provider != "" {
+		var ok bool
+		if p, ok = providers[entry.Provider]; !ok {
+			return nil, fmt.Errorf("unknown provider %q", entry.Provider)
 		}
+	}
+	res, err := process(cfg, opts, p, entry.Headers, entry.Body, false)
+	if err != nil {
+		return nil, err
+	}
+	return res.workflows, nil
+}
 
-		// Event header — show "pr_comment" instead of "issue_comment" for PR comments
-		displayEvent := eventType
-		if eventType == "issue_comment" && event.Issue.PullRequest != nil {
-			displayEvent = "pr_comment"
+func process(cfg *config.Config, opts Options, p provider, header http.Header, body []byte, duplicate bool) (result, error) {
+	eventType := p.eventType(header)
+	deliveryID := p.deliveryID(header)
+
+	ev, ignored, err := p.parse(eventType, body)
+	if err != nil {
+		return result{}, err
+	}
+	if ev == nil {
+		return result{status: http.StatusOK, message: ignored}, nil
+	}
+	// Check runs reported through github_app make GitHub send a check_suite
+	// for the app, which would loop like hookrunner/* check runs.
+	if ev.AppID != 0 && ev.AppID == cfg.GitHubApp.AppID {
+		log.Printf("Event %s from %s ignored: check suite of hookrunner's own app", ev.Type, ev.Vars.RepoFullName)
+		return result{status: http.StatusOK, message: "event ignored"}, nil
+	}
+	if bodyEvents[ev.Type] && strings.Contains(ev.Match, report.MarkerPrefix) {
+		log.Printf("Event %s from %s ignored: hookrunner's own report comment", ev.Type, ev.Vars.RepoFullName)
+		return result{status: http.StatusOK, message: "event ignored"}, nil
+	}
+	if len(cfg.Repos) > 0 && !repoAllowed(cfg.Repos, ev.Vars.RepoFullName) {
+		log.Printf("Event %s from %q ignored: repository not in repos allowlist", ev.Type, ev.Vars.RepoFullName)
+		return result{status: http.StatusOK, message: "repository not allowed"}, nil
+	}
+	if ignored := ignoredAuthor(cfg.IgnoreAuthors, ev); ignored != "" {
+		log.Printf("Event %s from %s ignored: %s is in ignore_authors", ev.Type, ev.Vars.RepoFullName, ignored)
+		return result{status: http.StatusOK, message: "author ignored"}, nil
+	}
+	if actionIgnored(cfg.Workflows, ev) {
+		return result{status: http.StatusOK, message: "action ignored"}, nil
+	}
+
+	log.Printf("════════════════════════════════════════")
+	log.Printf("EVENT: %s [%s] on %s by %s", ev.Display, ev.Action, ev.subject(), ev.Author)
+	if ev.Vars.CommentBody != "" {
+		log.Printf("Body: %s", ev.Vars.CommentBody)
+	}
+
+	var matched []string
+	skippedDuplicate := false
+	for name, wf := range cfg.Workflows {
+		if wf.Source != "" || !contains(wf.Events, ev.Type) || !actionAllowed(ev, wf) {
+			continue
 		}
-		log.Printf("════════════════════════════════════════")
-		log.Printf("EVENT: %s [%s] on %s#%d by %s",
-			displayEvent, event.Action, event.Repository.FullName, prNumber, commentAuthor)
-		if commentBody != "" {
-			log.Printf("Body: %s", commentBody)
+		if len(wf.Repos) > 0 && !repoAllowed(wf.Repos, ev.Vars.RepoFullName) {
+			continue
 		}
-
-		vars := workflow.TemplateVars{
-			RepoFullName:  event.Repository.FullName,
-			RepoCloneURL:  event.Repository.CloneURL,
-			PRNumber:      fmt.Sprintf("%d", prNumber),
-			CommentBody:   commentBody,
-			CommentAuthor: commentAuthor,
-			EventType:     eventType,
+		ignoreBots := cfg.IgnoreBots
+		if wf.IgnoreBots != nil {
+			ignoreBots = *wf.IgnoreBots
 		}
-
-		matched := false
-		for name, wf := range cfg.Workflows {
-			if !eventMatches(eventType, wf.Events) {
+		if ignoreBots && ev.fromBot() {
+			log.Printf("Workflow %q skipped: event from bot", name)
+			continue
+		}
+		if ignoredAuthor(wf.IgnoreAuthors, ev) != "" {
+			continue
+		}
+		if !refMatches(ev, wf) {
+			continue
+		}
+		if len(wf.Labels) > 0 && !labelsMatch(ev, wf.Labels) {
+			continue
+		}
+		re, err := regexp.Compile(wf.Trigger)
+		if err != nil {
+			log.Printf("Invalid trigger regex for workflow %q: %v", name, err)
+			continue
+		}
+		if re.MatchString(ev.Match) {
+			// An edit only counts when it is what made the trigger match.
+			if ev.Action == "edited" && bodyEvents[ev.Type] && (ev.Previous == nil || re.MatchString(*ev.Previous)) {
+				log.Printf("Workflow %q skipped: trigger already matched before the edit", name)
 				continue
 			}
-			if len(wf.Authors) > 0 && !authorAllowed(commentAuthor, wf.Authors) {
+			vars := ev.Vars
+			vars.Match = captures(re, ev.Match)
+			if wf.CommandName != "" {
+				inv, err := command.Find(ev.Match, re, wf)
+				if err != nil {
+					log.Printf("Workflow %q skipped: invalid %s command: %v", name, wf.CommandName, err)
+					continue
+				}
+				if inv == nil {
+					continue
+				}
+				vars.Subcommand, vars.Args, vars.Flags = inv.Name, inv.Args, inv.Flags
+			}
+			// Checked last: team membership may need GitHub API calls.
+			if !authorized(ev, wf, opts.Teams) {
 				continue
 			}
-			re, err := regexp.Compile(wf.Trigger)
-			if err != nil {
-				log.Printf("Invalid trigger regex for workflow %q: %v", name, err)
+			if duplicate && !wf.AllowRedelivery {
+				log.Printf("Workflow %q skipped: duplicate delivery %s", name, deliveryID)
+				skippedDuplicate = true
+				continue
+			}
+			log.Printf("Matched workflow: %q", name)
+			job := &queue.Job{Workflow: name, Vars: vars, Delivery: deliveryID, Author: ev.Author, Installation: ev.Installation}
+			if err := opts.Queue.Submit(job); err != nil {
+				log.Printf("Workflow %q: failed to enqueue: %v", name, err)
 				continue
 			}
-			if re.MatchString(matchString) {
-				matched = true
-				log.Printf("Matched workflow: %q", name)
-				go workflow.Execute(name, wf, vars)
+			matched = append(matched, name)
+			log.Printf("Queued workflow %q as job %s", name, job.ID)
+		}
+	}
+
+	if len(matched) > 0 {
+		return result{status: http.StatusAccepted, message: "workflow dispatched", workflows: matched}, nil
+	}
+	if skippedDuplicate {
+		log.Printf("════════════════════════════════════════")
+		return result{status: http.StatusOK, message: "duplicate delivery"}, nil
+	}
+	log.Printf("No matching workflow")
+	log.Printf("════════════════════════════════════════")
+	return result{status: http.StatusOK, message: "no matching workflow"}, nil
+}
+
+// captures returns the named groups of re's first match in s. Groups that
+// did not take part in the match are empty.
+func captures(re *regexp.Regexp, s string) map[string]string {
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return nil
+	}
+	var groups map[string]string
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if groups == nil {
+			groups = map[string]string{}
+		}
+		groups[name] = m[i]
+	}
+	return groups
+}
+
+// ignoredAuthor returns the sender or author of ev if it is in denylist.
+func ignoredAuthor(denylist []string, ev *event) string {
+	for _, login := range []string{ev.Sender, ev.Author} {
+		if login != "" && containsFold(denylist, login) {
+			return login
+		}
+	}
+	return ""
+}
+
+// authorized applies a workflow's authors, author_association and teams
+// filters. When several are set, passing any one of them is enough.
+func authorized(ev *event, wf config.WorkflowConfig, teams TeamChecker) bool {
+	if len(wf.Authors) == 0 && len(wf.AuthorAssociation) == 0 && len(wf.Teams) == 0 {
+		return true
+	}
+	if containsFold(wf.Authors, ev.Author) {
+		return true
+	}
+	if ev.Association != "" && containsFold(wf.AuthorAssociation, ev.Association) {
+		return true
+	}
+	if len(wf.Teams) == 0 || teams == nil || ev.Author == "" || ev.Vars.Provider != githubProvider.name {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(github.WithInstallation(context.Background(), ev.Installation), 5*time.Second)
+	defer cancel()
+	owner, _, _ := strings.Cut(ev.Vars.RepoFullName, "/")
+	for _, t := range wf.Teams {
+		org, slug, ok := strings.Cut(t, "/")
+		if !ok {
+			org, slug = owner, t
+		}
+		member, err := teams.IsMember(ctx, org, slug, ev.Author)
+		if err != nil {
+			log.Printf("Failed to check membership of %s in team %s/%s: %v", ev.Author, org, slug, err)
+			continue
+		}
+		if member {
+			return true
+		}
+	}
+	return false
+}
+
+// repoAllowed reports whether repo matches one of the globs, such as
+// "myorg/api-*". Repository names are case-insensitive.
+func repoAllowed(globs []string, repo string) bool {
+	repo = strings.ToLower(repo)
+	for _, g := range globs {
+		if matchGlob(strings.ToLower(g), repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// refMatches applies a workflow's branches and paths filters to push events.
+// A push to a tag never matches a branches filter, and a push matches a paths
+// filter when any file it changed does.
+func refMatches(ev *event, wf config.WorkflowConfig) bool {
+	if ev.Type != "push" {
+		return true
+	}
+	// There is nothing left to build; the delete event covers deletions.
+	if ev.Deleted {
+		return false
+	}
+	if len(wf.Branches) > 0 && (ev.Branch == "" || !anyGlobMatches(wf.Branches, ev.Branch)) {
+		return false
+	}
+	if len(wf.Paths) > 0 {
+		for _, f := range ev.Files {
+			if anyGlobMatches(wf.Paths, f) {
+				return true
 			}
 		}
+		return false
+	}
+	return true
+}
 
-		if matched {
-			w.WriteHeader(http.StatusAccepted)
-			w.Write([]byte("workflow dispatched\n"))
-		} else {
-			log.Printf("No matching workflow")
-			log.Printf("════════════════════════════════════════")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("no matching workflow\n"))
+// defaultActions are the actions accepted by workflows that list none for an
+// event, where not every action is worth running on. Other events accept all
+// actions by default.
+var defaultActions = map[string][]string{
+	"issue_comment":               {"created"},
+	"pull_request_review_comment": {"created"},
+	"pull_request_review":         {"submitted"},
+	"discussion_comment":          {"created"},
+	"discussion":                  {"created"},
+}
+
+// actionAllowed applies wf's actions list to ev. Only the listed actions that
+// exist for ev's event type count, so one list can serve several events;
+// when none do, the event's default applies.
+func actionAllowed(ev *event, wf config.WorkflowConfig) bool {
+	var actions []string
+	for _, a := range wf.Actions {
+		if contains(config.EventActions[ev.Type], a) {
+			actions = append(actions, a)
+		}
+	}
+	if len(actions) == 0 {
+		defaults, ok := defaultActions[ev.Type]
+		if !ok {
+			return true
 		}
+		actions = defaults
 	}
+	return contains(actions, ev.Action)
+}
+
+// actionIgnored reports whether workflows listen for ev's event type but
+// none of them accepts its action.
+func actionIgnored(workflows map[string]config.WorkflowConfig, ev *event) bool {
+	listening := false
+	for _, wf := range workflows {
+		if wf.Source != "" || !contains(wf.Events, ev.Type) {
+			continue
+		}
+		if actionAllowed(ev, wf) {
+			return false
+		}
+		listening = true
+	}
+	return listening
+}
+
+// labelsMatch reports whether the label added or removed by ev, or for other
+// actions any label on its issue or PR, is one of labels.
+func labelsMatch(ev *event, labels []string) bool {
+	if ev.Vars.Label != "" {
+		return containsFold(labels, ev.Vars.Label)
+	}
+	for _, l := range ev.Labels {
+		if containsFold(labels, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
 }
 
-func eventMatches(eventType string, events []string) bool {
-	for _, e := range events {
-		if e == eventType {
+func anyAllowsRedelivery(workflows map[string]config.WorkflowConfig) bool {
+	for _, wf := range workflows {
+		if wf.AllowRedelivery {
 			return true
 		}
 	}
 	return false
 }
 
-func authorAllowed(author string, allowed []string) bool {
-	for _, a := range allowed {
-		if strings.EqualFold(a, author) {
+// containsFold reports whether list contains s, ignoring case. GitHub logins
+// and label names are both case-insensitive.
+func containsFold(list []string, s string) bool {
+	for _, a := range list {
+		if strings.EqualFold(a, s) {
 			return true
 		}
 	}
@@ -1,16 +1,25 @@
 package webhook
 
 import (
+	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
 	"hookrunner/internal/config"
+	"hookrunner/internal/dedup"
+	"hookrunner/internal/journal"
+	"hookrunner/internal/queue"
+	"hookrunner/internal/workflow"
 )
 
 func computeHMAC(payload, secret string) string {
@@ -86,6 +95,12 @@ func makePRPayload(action, repo string, prNum int, merged bool) string {
 	return string(data)
 }
 
+func newTestQueue(cfg *config.Config) *queue.Queue {
+	return queue.New(cfg, func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+		return workflow.Result{}
+	})
+}
+
 func TestWebhookHandler(t *testing.T) {
 	secret := "test-secret"
 	cfg := &config.Config{
@@ -101,7 +116,7 @@ func TestWebhookHandler(t *testing.T) {
 		},
 	}
 
-	handler := Handler(cfg)
+	handler := Handler(cfg, Options{Queue: newTestQueue(cfg)})
 
 	t.Run("rejects GET", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/webhook", nil)
@@ -251,7 +266,7 @@ func TestPullRequestReview(t *testing.T) {
 		},
 	}
 
-	handler := Handler(cfg)
+	handler := Handler(cfg, Options{Queue: newTestQueue(cfg)})
 
 	t.Run("dispatches on review with /cc", func(t *testing.T) {
 		body := makeReviewPayload("submitted", "/cc @claude please review", "org/repo", 42)
@@ -311,7 +326,7 @@ func TestPullRequestMergeWorkflow(t *testing.T) {
 		},
 	}
 
-	handler := Handler(cfg)
+	handler := Handler(cfg, Options{Queue: newTestQueue(cfg)})
 
 	t.Run("dispatches on merged PR", func(t *testing.T) {
 		body := makePRPayload("closed", "org/repo", 42, true)
@@ -366,3 +381,1409 @@ func TestPullRequestMergeWorkflow(t *testing.T) {
 		}
 	})
 }
+
+func TestDuplicateDelivery(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"test": {
+				Events:  []string{"issue_comment"},
+				Trigger: `/cc\s+@claude`,
+				Command: "echo test",
+				Timeout: 5,
+			},
+		},
+	}
+
+	send := func(handler http.HandlerFunc, delivery string) *httptest.ResponseRecorder {
+		body := makeCommentPayload("created", "/cc @claude", "org/repo", 42)
+		req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", computeHMAC(body, secret))
+		req.Header.Set("X-GitHub-Event", "issue_comment")
+		req.Header.Set("X-GitHub-Delivery", delivery)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	t.Run("ignores redelivered GUID", func(t *testing.T) {
+		deliveries, _ := dedup.Open("", 10)
+		handler := Handler(cfg, Options{Queue: newTestQueue(cfg), Deliveries: deliveries})
+
+		if w := send(handler, "guid-1"); w.Code != http.StatusAccepted {
+			t.Fatalf("expected 202 for first delivery, got %d", w.Code)
+		}
+		w := send(handler, "guid-1")
+		if w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "duplicate delivery") {
+			t.Errorf("expected 'duplicate delivery', got %q", w.Body.String())
+		}
+		if w := send(handler, "guid-2"); w.Code != http.StatusAccepted {
+			t.Errorf("expected 202 for new delivery, got %d", w.Code)
+		}
+	})
+
+	t.Run("workflow can opt out", func(t *testing.T) {
+		optOut := &config.Config{
+			WebhookSecret: secret,
+			Port:          7890,
+			Workflows: map[string]config.WorkflowConfig{
+				"test": {
+					Events:          []string{"issue_comment"},
+					Trigger:         `/cc\s+@claude`,
+					Command:         "echo test",
+					Timeout:         5,
+					AllowRedelivery: true,
+				},
+			},
+		}
+		deliveries, _ := dedup.Open("", 10)
+		handler := Handler(optOut, Options{Queue: newTestQueue(optOut), Deliveries: deliveries})
+
+		send(handler, "guid-1")
+		if w := send(handler, "guid-1"); w.Code != http.StatusAccepted {
+			t.Errorf("expected 202 for redelivery, got %d", w.Code)
+		}
+	})
+}
+
+func TestJournalAndReplay(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"test": {
+				Events:  []string{"issue_comment"},
+				Trigger: `/cc\s+@claude`,
+				Command: "echo test",
+				Timeout: 5,
+			},
+		},
+	}
+
+	j := journal.Open(t.TempDir())
+	deliveries, _ := dedup.Open("", 10)
+	handler := Handler(cfg, Options{Queue: newTestQueue(cfg), Deliveries: deliveries, Journal: j})
+
+	body := makeCommentPayload("created", "/cc @claude", "org/repo", 42)
+	req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", computeHMAC(body, secret))
+	req.Header.Set("X-GitHub-Event", "issue_comment")
+	req.Header.Set("X-GitHub-Delivery", "guid-1")
+	w := httptest.NewRecorder()
+	handler(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", w.Code)
+	}
+
+	entry, err := j.Find("guid-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Event != "issue_comment" || len(entry.Workflows) != 1 || entry.Workflows[0] != "test" {
+		t.Errorf("unexpected journal entry: %+v", entry)
+	}
+
+	var jobs []*queue.Job
+	q := queue.New(cfg, func(ctx context.Context, job *queue.Job, wf config.WorkflowConfig) workflow.Result {
+		jobs = append(jobs, job)
+		return workflow.Result{}
+	})
+	workflows, err := Replay(cfg, Options{Queue: q}, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Wait()
+	if len(workflows) != 1 || workflows[0] != "test" {
+		t.Errorf("replay matched %v, want [test]", workflows)
+	}
+	if len(jobs) != 1 || jobs[0].Vars.PRNumber != "42" || jobs[0].Delivery != "guid-1" {
+		t.Errorf("unexpected replayed jobs: %+v", jobs)
+	}
+}
+
+func postEvent(handler http.HandlerFunc, secret, eventType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", computeHMAC(body, secret))
+	req.Header.Set("X-GitHub-Event", eventType)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+// newRecordingQueue returns a test queue and the jobs submitted to it, in
+// order.
+func newRecordingQueue(cfg *config.Config) (*queue.Queue, *[]*queue.Job) {
+	var jobs []*queue.Job
+	q := newTestQueue(cfg)
+	q.OnSubmit(func(job *queue.Job, wf config.WorkflowConfig) {
+		jobs = append(jobs, job)
+	})
+	return q, &jobs
+}
+
+func makePushPayload(ref string, files ...string) string {
+	event := map[string]interface{}{
+		"ref":    ref,
+		"before": "1111111",
+		"after":  "2222222",
+		"pusher": map[string]interface{}{"name": "octocat"},
+		"sender": map[string]interface{}{"login": "octocat"},
+		"commits": []map[string]interface{}{
+			{"added": files[:len(files)/2], "modified": files[len(files)/2:], "removed": []string{}},
+		},
+		"repository": map[string]interface{}{
+			"full_name": "org/repo",
+			"clone_url": "https://github.com/org/repo.git",
+		},
+	}
+	data, _ := json.Marshal(event)
+	return string(data)
+}
+
+func TestPushWorkflow(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"build": {
+				Events:   []string{"push"},
+				Branches: []string{"main", "release/*"},
+				Paths:    []string{"src/**", "go.mod"},
+				Trigger:  `.*`,
+				Command:  "make",
+				Timeout:  5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	tests := []struct {
+		name  string
+		ref   string
+		files []string
+		want  int
+	}{
+		{"matching branch and path", "refs/heads/main", []string{"README.md", "src/cmd/main.go"}, http.StatusAccepted},
+		{"branch glob", "refs/heads/release/1.2", []string{"go.mod"}, http.StatusAccepted},
+		{"other branch", "refs/heads/feature", []string{"go.mod"}, http.StatusOK},
+		{"glob does not cross slash", "refs/heads/release/1.2/hotfix", []string{"go.mod"}, http.StatusOK},
+		{"tag push", "refs/tags/main", []string{"go.mod"}, http.StatusOK},
+		{"no matching path", "refs/heads/main", []string{"docs/index.md"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postEvent(handler, secret, "push", makePushPayload(tt.ref, tt.files...))
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d (%s)", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("branch deletion", func(t *testing.T) {
+		var payload map[string]interface{}
+		json.Unmarshal([]byte(makePushPayload("refs/heads/main", "src/cmd/main.go")), &payload)
+		payload["deleted"] = true
+		payload["after"] = "0000000000000000000000000000000000000000"
+		body, _ := json.Marshal(payload)
+		if w := postEvent(handler, secret, "push", string(body)); w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d (%s)", w.Code, w.Body.String())
+		}
+	})
+
+	if len(*jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(*jobs))
+	}
+	if author := (*jobs)[0].Author; author != "octocat" {
+		t.Errorf("job author = %q, want octocat", author)
+	}
+	vars := (*jobs)[0].Vars
+	if vars.Ref != "refs/heads/main" || vars.BeforeSHA != "1111111" || vars.AfterSHA != "2222222" ||
+		vars.Pusher != "octocat" || vars.HeadSHA != "2222222" || vars.EventType != "push" {
+		t.Errorf("unexpected vars: %+v", vars)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"main", "main", true},
+		{"release/*", "release/1.0", true},
+		{"release/*", "release/1.0/rc", false},
+		{"src/**", "src/a/b/c.go", true},
+		{"src/**", "src", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/x/main.go", true},
+		{"**/*.go", "cmd/x/main.py", false},
+		{"docs/**/*.md", "docs/a/b.md", true},
+		{"*.md", "docs/a.md", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func makeIssuePayload(action, label string, labels ...string) string {
+	var current []map[string]interface{}
+	for _, l := range labels {
+		current = append(current, map[string]interface{}{"name": l})
+	}
+	event := map[string]interface{}{
+		"action": action,
+		"issue": map[string]interface{}{
+			"number": 7,
+			"title":  "Crash on startup",
+			"state":  "open",
+			"labels": current,
+		},
+		"sender": map[string]interface{}{"login": "triager"},
+		"repository": map[string]interface{}{
+			"full_name": "org/repo",
+			"clone_url": "https://github.com/org/repo.git",
+		},
+	}
+	if label != "" {
+		event["label"] = map[string]interface{}{"name": label}
+	}
+	data, _ := json.Marshal(event)
+	return string(data)
+}
+
+func TestLabelWorkflow(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"repro": {
+				Events:  []string{"issues", "pull_request"},
+				Labels:  []string{"needs-repro"},
+				Trigger: `^labeled:`,
+				Command: "./repro.sh",
+				Timeout: 5,
+			},
+			"triage": {
+				Events:  []string{"issues"},
+				Labels:  []string{"bug"},
+				Trigger: `^opened:open$`,
+				Command: "./triage.sh",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	t.Run("issue labeled", func(t *testing.T) {
+		w := postEvent(handler, secret, "issues", makeIssuePayload("labeled", "Needs-Repro", "needs-repro"))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d", w.Code)
+		}
+		vars := (*jobs)[len(*jobs)-1].Vars
+		if vars.Label != "Needs-Repro" || vars.IssueTitle != "Crash on startup" || vars.PRNumber != "7" {
+			t.Errorf("unexpected vars: %+v", vars)
+		}
+	})
+
+	t.Run("other label added", func(t *testing.T) {
+		// The issue already carries needs-repro, but the filter applies to
+		// the label that changed.
+		w := postEvent(handler, secret, "issues", makeIssuePayload("labeled", "bug", "needs-repro", "bug"))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("unlabeled does not match trigger", func(t *testing.T) {
+		w := postEvent(handler, secret, "issues", makeIssuePayload("unlabeled", "needs-repro"))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("opened with label", func(t *testing.T) {
+		w := postEvent(handler, secret, "issues", makeIssuePayload("opened", "", "bug"))
+		if w.Code != http.StatusAccepted {
+			t.Errorf("expected 202, got %d", w.Code)
+		}
+		if w := postEvent(handler, secret, "issues", makeIssuePayload("opened", "")); w.Code != http.StatusOK {
+			t.Errorf("expected 200 for unlabeled issue, got %d", w.Code)
+		}
+	})
+
+	t.Run("pull request labeled", func(t *testing.T) {
+		event := map[string]interface{}{
+			"action": "labeled",
+			"label":  map[string]interface{}{"name": "needs-repro"},
+			"pull_request": map[string]interface{}{
+				"number": 42,
+				"title":  "Fix crash",
+				"merged": false,
+			},
+			"repository": map[string]interface{}{"full_name": "org/repo"},
+		}
+		data, _ := json.Marshal(event)
+		w := postEvent(handler, secret, "pull_request", string(data))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d", w.Code)
+		}
+		vars := (*jobs)[len(*jobs)-1].Vars
+		if vars.Label != "needs-repro" || vars.IssueTitle != "Fix crash" || vars.PRNumber != "42" {
+			t.Errorf("unexpected vars: %+v", vars)
+		}
+	})
+}
+
+func makeCIPayload(eventType, action, conclusion string) string {
+	run := map[string]interface{}{
+		"name":          "CI",
+		"conclusion":    conclusion,
+		"head_branch":   "fix-crash",
+		"head_sha":      "abc123",
+		"html_url":      "https://github.com/org/repo/actions/runs/1",
+		"pull_requests": []map[string]interface{}{{"number": 42}},
+	}
+	key := eventType
+	switch eventType {
+	case "check_run":
+		delete(run, "head_branch")
+		run["check_suite"] = map[string]interface{}{"head_branch": "fix-crash"}
+	case "check_suite":
+		delete(run, "name")
+		delete(run, "html_url")
+		run["app"] = map[string]interface{}{"name": "CI"}
+	}
+	event := map[string]interface{}{
+		"action": action,
+		key:      run,
+		"repository": map[string]interface{}{
+			"full_name": "org/repo",
+			"clone_url": "https://github.com/org/repo.git",
+		},
+	}
+	data, _ := json.Marshal(event)
+	return string(data)
+}
+
+func TestCIFailureWorkflow(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		GitHubApp:     config.GitHubAppConfig{AppID: 99},
+		Workflows: map[string]config.WorkflowConfig{
+			"diagnose": {
+				Events:  []string{"workflow_run", "check_run", "check_suite"},
+				Trigger: `^completed:failure$`,
+				Command: "./diagnose.sh",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	for _, eventType := range []string{"workflow_run", "check_run", "check_suite"} {
+		t.Run(eventType, func(t *testing.T) {
+			if w := postEvent(handler, secret, eventType, makeCIPayload(eventType, "completed", "success")); w.Code != http.StatusOK {
+				t.Errorf("expected 200 for success, got %d", w.Code)
+			}
+			if w := postEvent(handler, secret, eventType, makeCIPayload(eventType, "requested", "")); w.Code != http.StatusOK {
+				t.Errorf("expected 200 for requested, got %d", w.Code)
+			}
+			w := postEvent(handler, secret, eventType, makeCIPayload(eventType, "completed", "failure"))
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("expected 202 for failure, got %d", w.Code)
+			}
+			vars := (*jobs)[len(*jobs)-1].Vars
+			want := workflow.TemplateVars{
+				RepoFullName: "org/repo",
+				RepoCloneURL: "https://github.com/org/repo.git",
+				PRNumber:     "42",
+				EventType:    eventType,
+				Provider:     "github",
+				HeadSHA:      "abc123",
+				RunName:      "CI",
+				Conclusion:   "failure",
+				HeadBranch:   "fix-crash",
+				HTMLURL:      "https://github.com/org/repo/actions/runs/1",
+			}
+			if eventType == "check_suite" {
+				want.HTMLURL = ""
+			}
+			if !reflect.DeepEqual(vars, want) {
+				t.Errorf("vars = %+v, want %+v", vars, want)
+			}
+		})
+	}
+
+	t.Run("ignores own check runs", func(t *testing.T) {
+		body := strings.Replace(makeCIPayload("check_run", "completed", "failure"), `"name":"CI"`, `"name":"hookrunner/diagnose"`, 1)
+		w := postEvent(handler, secret, "check_run", body)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "event ignored") {
+			t.Errorf("expected event ignored, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("ignores own check suites", func(t *testing.T) {
+		body := strings.Replace(makeCIPayload("check_suite", "completed", "failure"), `"app":{"name":"CI"}`, `"app":{"id":99,"name":"hookrunner"}`, 1)
+		w := postEvent(handler, secret, "check_suite", body)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "event ignored") {
+			t.Errorf("expected event ignored, got %d %q", w.Code, w.Body.String())
+		}
+	})
+}
+
+func makeReleasePayload(action, tag, name string) string {
+	event := map[string]interface{}{
+		"action": action,
+		"release": map[string]interface{}{
+			"tag_name": tag,
+			"name":     name,
+			"html_url": "https://github.com/org/repo/releases/tag/" + tag,
+		},
+		"repository": map[string]interface{}{
+			"full_name": "org/repo",
+			"clone_url": "https://github.com/org/repo.git",
+		},
+	}
+	data, _ := json.Marshal(event)
+	return string(data)
+}
+
+func makeRefPayload(refType, ref string) string {
+	event := map[string]interface{}{
+		"ref":      ref,
+		"ref_type": refType,
+		"repository": map[string]interface{}{
+			"full_name": "org/repo",
+			"clone_url": "https://github.com/org/repo.git",
+		},
+	}
+	data, _ := json.Marshal(event)
+	return string(data)
+}
+
+func makeDeploymentPayload(eventType, state, environment string) string {
+	event := map[string]interface{}{
+		"action": "created",
+		"deployment": map[string]interface{}{
+			"id":          99,
+			"environment": environment,
+			"sha":         "abc123",
+		},
+		"repository": map[string]interface{}{
+			"full_name": "org/repo",
+			"clone_url": "https://github.com/org/repo.git",
+		},
+	}
+	if eventType == "deployment_status" {
+		event["deployment_status"] = map[string]interface{}{
+			"state":       state,
+			"environment": environment,
+			"target_url":  "https://example.com/deploy/99",
+		}
+	}
+	data, _ := json.Marshal(event)
+	return string(data)
+}
+
+func TestReleaseWorkflow(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"package": {
+				Events:  []string{"release"},
+				Trigger: `^(published|prereleased):v`,
+				Command: "./package.sh {{.TagName}}",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	t.Run("dispatches on published release", func(t *testing.T) {
+		w := postEvent(handler, secret, "release", makeReleasePayload("published", "v1.2.0", "Version 1.2"))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d", w.Code)
+		}
+		vars := (*jobs)[len(*jobs)-1].Vars
+		if vars.TagName != "v1.2.0" || vars.ReleaseName != "Version 1.2" || vars.HTMLURL == "" {
+			t.Errorf("unexpected vars: %+v", vars)
+		}
+	})
+
+	t.Run("dispatches on prerelease", func(t *testing.T) {
+		w := postEvent(handler, secret, "release", makeReleasePayload("prereleased", "v1.3.0-rc1", ""))
+		if w.Code != http.StatusAccepted {
+			t.Errorf("expected 202, got %d", w.Code)
+		}
+	})
+
+	t.Run("ignores draft", func(t *testing.T) {
+		w := postEvent(handler, secret, "release", makeReleasePayload("created", "v1.3.0", ""))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", w.Code)
+		}
+	})
+}
+
+func TestTagWorkflow(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"tagged": {
+				Events:  []string{"create", "delete"},
+				Trigger: `^tag:`,
+				Command: "echo {{.TagName}}",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	for _, eventType := range []string{"create", "delete"} {
+		w := postEvent(handler, secret, eventType, makeRefPayload("tag", "v2.0.0"))
+		if w.Code != http.StatusAccepted {
+			t.Errorf("%s tag: expected 202, got %d", eventType, w.Code)
+		}
+		w = postEvent(handler, secret, eventType, makeRefPayload("branch", "feature"))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s branch: expected 200, got %d", eventType, w.Code)
+		}
+	}
+	if len(*jobs) != 2 || (*jobs)[0].Vars.TagName != "v2.0.0" || (*jobs)[1].Vars.EventType != "delete" {
+		t.Errorf("unexpected jobs: %+v", *jobs)
+	}
+}
+
+func TestDeploymentWorkflow(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"deploy": {
+				Events:  []string{"deployment"},
+				Trigger: `^created:production$`,
+				Command: "./deploy.sh",
+				Timeout: 5,
+			},
+			"smoke": {
+				Events:  []string{"deployment_status"},
+				Trigger: `^success:`,
+				Command: "./smoke.sh",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	if w := postEvent(handler, secret, "deployment", makeDeploymentPayload("deployment", "", "staging")); w.Code != http.StatusOK {
+		t.Errorf("staging deployment: expected 200, got %d", w.Code)
+	}
+	if w := postEvent(handler, secret, "deployment", makeDeploymentPayload("deployment", "", "production")); w.Code != http.StatusAccepted {
+		t.Errorf("production deployment: expected 202, got %d", w.Code)
+	}
+	if w := postEvent(handler, secret, "deployment_status", makeDeploymentPayload("deployment_status", "failure", "staging")); w.Code != http.StatusOK {
+		t.Errorf("failed deployment status: expected 200, got %d", w.Code)
+	}
+	if w := postEvent(handler, secret, "deployment_status", makeDeploymentPayload("deployment_status", "success", "staging")); w.Code != http.StatusAccepted {
+		t.Errorf("successful deployment status: expected 202, got %d", w.Code)
+	}
+
+	if len(*jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(*jobs))
+	}
+	vars := (*jobs)[1].Vars
+	if vars.Environment != "staging" || vars.DeploymentID != "99" || vars.HeadSHA != "abc123" || vars.HTMLURL != "https://example.com/deploy/99" {
+		t.Errorf("unexpected vars: %+v", vars)
+	}
+}
+
+func makeDiscussionPayload(eventType, action, body string) string {
+	event := map[string]interface{}{
+		"action": action,
+		"discussion": map[string]interface{}{
+			"number":   12,
+			"title":    "RFC: new config format",
+			"body":     "Proposal text",
+			"category": map[string]interface{}{"name": "RFCs"},
+			"user":     map[string]interface{}{"login": "author"},
+		},
+		"repository": map[string]interface{}{
+			"full_name": "org/repo",
+			"clone_url": "https://github.com/org/repo.git",
+		},
+	}
+	if eventType == "discussion_comment" {
+		event["comment"] = map[string]interface{}{
+			"id":   555,
+			"body": body,
+			"user": map[string]interface{}{"login": "testuser"},
+		}
+	} else {
+		event["discussion"].(map[string]interface{})["body"] = body
+	}
+	data, _ := json.Marshal(event)
+	return string(data)
+}
+
+func TestDiscussionWorkflow(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"test": {
+				Events:  []string{"discussion", "discussion_comment"},
+				Trigger: `/cc\s+@claude`,
+				Command: "echo test",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	t.Run("dispatches on discussion comment", func(t *testing.T) {
+		w := postEvent(handler, secret, "discussion_comment", makeDiscussionPayload("discussion_comment", "created", "/cc @claude summarize"))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d", w.Code)
+		}
+		vars := (*jobs)[len(*jobs)-1].Vars
+		if vars.CommentBody != "/cc @claude summarize" || vars.CommentAuthor != "testuser" || vars.CommentID != "555" ||
+			vars.DiscussionNumber != "12" || vars.DiscussionCategory != "RFCs" || vars.DiscussionTitle != "RFC: new config format" {
+			t.Errorf("unexpected vars: %+v", vars)
+		}
+	})
+
+	t.Run("dispatches on new discussion", func(t *testing.T) {
+		w := postEvent(handler, secret, "discussion", makeDiscussionPayload("discussion", "created", "/cc @claude please review"))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d", w.Code)
+		}
+		if vars := (*jobs)[len(*jobs)-1].Vars; vars.CommentAuthor != "author" || vars.DiscussionNumber != "12" {
+			t.Errorf("unexpected vars: %+v", vars)
+		}
+	})
+
+	t.Run("ignores edited comment", func(t *testing.T) {
+		w := postEvent(handler, secret, "discussion_comment", makeDiscussionPayload("discussion_comment", "edited", "/cc @claude"))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "action ignored") {
+			t.Errorf("expected action ignored, got %d %q", w.Code, w.Body.String())
+		}
+	})
+}
+
+func makeEditedCommentPayload(body, from string) string {
+	var event map[string]interface{}
+	json.Unmarshal([]byte(makeCommentPayload("edited", body, "org/repo", 42)), &event)
+	event["changes"] = map[string]interface{}{
+		"body": map[string]interface{}{"from": from},
+	}
+	data, _ := json.Marshal(event)
+	return string(data)
+}
+
+func TestEditedComments(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"test": {
+				Events:  []string{"issue_comment"},
+				Actions: []string{"created", "edited"},
+				Trigger: `/cc\s+@claude`,
+				Command: "echo test",
+				Timeout: 5,
+			},
+		},
+	}
+	handler := Handler(cfg, Options{Queue: newTestQueue(cfg)})
+
+	t.Run("created still dispatches", func(t *testing.T) {
+		w := postEvent(handler, secret, "issue_comment", makeCommentPayload("created", "/cc @claude", "org/repo", 42))
+		if w.Code != http.StatusAccepted {
+			t.Errorf("expected 202, got %d", w.Code)
+		}
+	})
+
+	t.Run("edit that fixes the trigger dispatches", func(t *testing.T) {
+		w := postEvent(handler, secret, "issue_comment", makeEditedCommentPayload("/cc @claude", "/cc @cluade"))
+		if w.Code != http.StatusAccepted {
+			t.Errorf("expected 202, got %d", w.Code)
+		}
+	})
+
+	t.Run("edit of an already matching comment is skipped", func(t *testing.T) {
+		w := postEvent(handler, secret, "issue_comment", makeEditedCommentPayload("/cc @claude please", "/cc @claude"))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "no matching workflow") {
+			t.Errorf("expected no matching workflow, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("edit without body change is skipped", func(t *testing.T) {
+		w := postEvent(handler, secret, "issue_comment", makeCommentPayload("edited", "/cc @claude", "org/repo", 42))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("deleted is ignored", func(t *testing.T) {
+		w := postEvent(handler, secret, "issue_comment", makeCommentPayload("deleted", "/cc @claude", "org/repo", 42))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "action ignored") {
+			t.Errorf("expected action ignored, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("pull_request edits match the status string", func(t *testing.T) {
+		cfg := &config.Config{
+			WebhookSecret: secret,
+			Port:          7890,
+			Workflows: map[string]config.WorkflowConfig{
+				"pr-edited": {
+					Events:  []string{"pull_request"},
+					Trigger: `^edited:`,
+					Command: "echo edited",
+					Timeout: 5,
+				},
+			},
+		}
+		handler := Handler(cfg, Options{Queue: newTestQueue(cfg)})
+
+		w := postEvent(handler, secret, "pull_request", makePRPayload("edited", "org/repo", 42, false))
+		if w.Code != http.StatusAccepted {
+			t.Errorf("title edit: expected 202, got %d %q", w.Code, w.Body.String())
+		}
+
+		var payload map[string]interface{}
+		json.Unmarshal([]byte(makePRPayload("edited", "org/repo", 42, false)), &payload)
+		payload["changes"] = map[string]interface{}{"body": map[string]interface{}{"from": "edited: old description"}}
+		body, _ := json.Marshal(payload)
+		w = postEvent(handler, secret, "pull_request", string(body))
+		if w.Code != http.StatusAccepted {
+			t.Errorf("description edit: expected 202, got %d %q", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestActionFilters(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"cleanup": {
+				Events:  []string{"pull_request", "issue_comment"},
+				Actions: []string{"closed"},
+				Trigger: `merged$|/cleanup`,
+				Command: "echo cleanup",
+				Timeout: 5,
+			},
+		},
+	}
+	handler := Handler(cfg, Options{Queue: newTestQueue(cfg)})
+
+	tests := []struct {
+		name, eventType, body string
+		want                  string
+	}{
+		{"listed action", "pull_request", makePRPayload("closed", "org/repo", 42, true), "workflow dispatched"},
+		{"unlisted action", "pull_request", makePRPayload("reopened", "org/repo", 42, true), "action ignored"},
+		{"other event keeps its default", "issue_comment", makeCommentPayload("created", "/cleanup", "org/repo", 42), "workflow dispatched"},
+		{"other event default excludes edits", "issue_comment", makeCommentPayload("edited", "/cleanup", "org/repo", 42), "action ignored"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postEvent(handler, secret, tt.eventType, tt.body)
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("expected %q, got %d %q", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRepoScoping(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Repos:         []string{"myorg/*"},
+		Workflows: map[string]config.WorkflowConfig{
+			"api": {
+				Repos:   []string{"myorg/api-*"},
+				Events:  []string{"issue_comment"},
+				Trigger: `/cc\s+@claude`,
+				Command: "echo api",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	tests := []struct {
+		repo string
+		want string
+	}{
+		{"myorg/api-users", "workflow dispatched"},
+		{"MyOrg/API-Billing", "workflow dispatched"},
+		{"myorg/web", "no matching workflow"},
+		{"otherorg/api-users", "repository not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			w := postEvent(handler, secret, "issue_comment", makeCommentPayload("created", "/cc @claude", tt.repo, 1))
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("expected %q, got %d %q", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+	if len(*jobs) != 2 {
+		t.Errorf("expected 2 jobs, got %d", len(*jobs))
+	}
+}
+
+type fakeTeams map[string]bool // "org/team/user"
+
+func (f fakeTeams) IsMember(ctx context.Context, org, team, user string) (bool, error) {
+	return f[org+"/"+team+"/"+user], nil
+}
+
+// countingTeams records how many membership lookups were made.
+type countingTeams struct{ calls int }
+
+func (c *countingTeams) IsMember(ctx context.Context, org, team, user string) (bool, error) {
+	c.calls++
+	return false, nil
+}
+
+func TestAuthorization(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"members": {
+				Events:            []string{"issue_comment"},
+				AuthorAssociation: []string{"OWNER", "member"},
+				Teams:             []string{"reviewers", "partner/contractors"},
+				Trigger:           `/cc\s+@claude`,
+				Command:           "echo test",
+				Timeout:           5,
+			},
+		},
+	}
+	teams := fakeTeams{"org/reviewers/alice": true, "partner/contractors/bob": true}
+	handler := Handler(cfg, Options{Queue: newTestQueue(cfg), Teams: teams})
+
+	comment := func(login, association string) string {
+		event := map[string]interface{}{
+			"action": "created",
+			"comment": map[string]interface{}{
+				"body":               "/cc @claude",
+				"author_association": association,
+				"user":               map[string]interface{}{"login": login},
+			},
+			"issue":      map[string]interface{}{"number": 1},
+			"repository": map[string]interface{}{"full_name": "org/repo"},
+		}
+		data, _ := json.Marshal(event)
+		return string(data)
+	}
+
+	tests := []struct {
+		name, login, association string
+		want                     int
+	}{
+		{"member", "mallory", "MEMBER", http.StatusAccepted},
+		{"owner", "octocat", "OWNER", http.StatusAccepted},
+		{"contributor", "mallory", "CONTRIBUTOR", http.StatusOK},
+		{"team in repo owner's org", "alice", "CONTRIBUTOR", http.StatusAccepted},
+		{"team in other org", "bob", "NONE", http.StatusAccepted},
+		{"not in any team", "carol", "NONE", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postEvent(handler, secret, "issue_comment", comment(tt.login, tt.association))
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+
+	t.Run("no team lookups without a trigger match", func(t *testing.T) {
+		counter := &countingTeams{}
+		handler := Handler(cfg, Options{Queue: newTestQueue(cfg), Teams: counter})
+		w := postEvent(handler, secret, "issue_comment", makeCommentPayload("created", "just a comment", "org/repo", 1))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", w.Code)
+		}
+		if counter.calls != 0 {
+			t.Errorf("expected no team lookups, got %d", counter.calls)
+		}
+	})
+}
+
+func TestIgnoreBotsAndAuthors(t *testing.T) {
+	secret := "test-secret"
+	allowBots := false
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		IgnoreBots:    true,
+		IgnoreAuthors: []string{"spammer"},
+		Workflows: map[string]config.WorkflowConfig{
+			"review": {
+				Events:        []string{"issue_comment"},
+				IgnoreAuthors: []string{"intern"},
+				Trigger:       `/cc\s+@claude`,
+				Command:       "echo review",
+				Timeout:       5,
+			},
+			"bot-friendly": {
+				Events:     []string{"issue_comment"},
+				IgnoreBots: &allowBots,
+				Trigger:    `/deps`,
+				Command:    "echo deps",
+				Timeout:    5,
+			},
+		},
+	}
+	handler := Handler(cfg, Options{Queue: newTestQueue(cfg)})
+
+	comment := func(login, senderType, body string) string {
+		event := map[string]interface{}{
+			"action": "created",
+			"comment": map[string]interface{}{
+				"body": body,
+				"user": map[string]interface{}{"login": login},
+			},
+			"sender":     map[string]interface{}{"login": login, "type": senderType},
+			"issue":      map[string]interface{}{"number": 1},
+			"repository": map[string]interface{}{"full_name": "org/repo"},
+		}
+		data, _ := json.Marshal(event)
+		return string(data)
+	}
+
+	tests := []struct {
+		name, login, senderType, body string
+		want                          string
+	}{
+		{"human", "octocat", "User", "/cc @claude", "workflow dispatched"},
+		{"bot type", "hookrunner-app", "Bot", "/cc @claude", "no matching workflow"},
+		{"bot login suffix", "github-actions[bot]", "User", "/cc @claude", "no matching workflow"},
+		{"workflow allows bots", "dependabot[bot]", "Bot", "/deps", "workflow dispatched"},
+		{"global denylist", "SPAMMER", "User", "/deps", "author ignored"},
+		{"workflow denylist", "intern", "User", "/cc @claude", "no matching workflow"},
+		{"own report comment", "octocat", "User", "Output:\n/cc @claude\n<!-- hookrunner:workflow=review -->", "event ignored"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postEvent(handler, secret, "issue_comment", comment(tt.login, tt.senderType, tt.body))
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("expected %q, got %d %q", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSlashCommand(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"review": {
+				Events:      []string{"issue_comment"},
+				Trigger:     `/cc\b`,
+				CommandName: "review",
+				CommandFlags: map[string]config.CommandFlag{
+					"model": {Values: []string{"opus", "sonnet"}, Default: "sonnet"},
+					"focus": {},
+				},
+				Command: "echo {{.Flags.model}} {{.Args}}",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"valid command", "/cc review --model=opus --focus security src/", http.StatusAccepted},
+		{"other subcommand", "/cc deploy", http.StatusOK},
+		{"invalid flag value", "/cc review --model=gpt", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postEvent(handler, secret, "issue_comment", makeCommentPayload("created", tt.body, "org/repo", 1))
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d (%s)", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if len(*jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d", len(*jobs))
+	}
+	vars := (*jobs)[0].Vars
+	wantFlags := map[string]string{"model": "opus", "focus": "security"}
+	if vars.Subcommand != "review" || vars.Args.String() != "src/" || !reflect.DeepEqual(vars.Flags, wantFlags) {
+		t.Errorf("unexpected command vars: %q %q %v", vars.Subcommand, vars.Args, vars.Flags)
+	}
+}
+
+func TestTriggerCaptures(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.Config{
+		WebhookSecret: secret,
+		Port:          7890,
+		Workflows: map[string]config.WorkflowConfig{
+			"run": {
+				Events:  []string{"issue_comment"},
+				Trigger: `^/cc (?P<target>\w+)(?: on (?P<env>\w+))?`,
+				Command: "echo {{.Match.target}}",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	for _, body := range []string{"/cc deploy on staging", "/cc lint"} {
+		w := postEvent(handler, secret, "issue_comment", makeCommentPayload("created", body, "org/repo", 1))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("%q: expected 202, got %d (%s)", body, w.Code, w.Body.String())
+		}
+	}
+
+	want := []map[string]string{
+		{"target": "deploy", "env": "staging"},
+		{"target": "lint", "env": ""},
+	}
+	for i, job := range *jobs {
+		if !reflect.DeepEqual(job.Vars.Match, want[i]) {
+			t.Errorf("job %d: Match = %v, want %v", i, job.Vars.Match, want[i])
+		}
+	}
+}
+
+func TestSourceWorkflow(t *testing.T) {
+	cfg := &config.Config{
+		WebhookSecret: "test-secret",
+		Port:          7890,
+		Sources: map[string]config.SourceConfig{
+			"alerts": {
+				Path:      "/hooks/alerts",
+				Auth:      "token",
+				Secret:    "s3cret",
+				Header:    "Authorization",
+				EventPath: "$.status",
+				Fields: map[string]string{
+					"severity": "$.alert.severity",
+					"name":     "$.alert.labels.alertname",
+				},
+			},
+		},
+		Workflows: map[string]config.WorkflowConfig{
+			"page": {
+				Source:  "alerts",
+				Events:  []string{"firing"},
+				Fields:  map[string]string{"severity": "^critical$"},
+				Command: "page {{.Fields.name}}",
+				Timeout: 5,
+			},
+			"review": {
+				Events:  []string{"issue_comment"},
+				Trigger: `.*`,
+				Command: "echo review",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := SourceHandler(cfg, "alerts", Options{Queue: q})
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/alerts", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	alert := func(status, severity string) string {
+		return `{"status": "` + status + `", "alert": {"severity": "` + severity + `", "labels": {"alertname": "DiskFull"}}}`
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		body  string
+		want  int
+	}{
+		{"matching alert", "s3cret", alert("firing", "critical"), http.StatusAccepted},
+		{"wrong token", "nope", alert("firing", "critical"), http.StatusForbidden},
+		{"field does not match", "s3cret", alert("firing", "warning"), http.StatusOK},
+		{"other event type", "s3cret", alert("resolved", "critical"), http.StatusOK},
+		{"invalid JSON", "s3cret", "{", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(tt.token, tt.body)
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d (%s)", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if len(*jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d", len(*jobs))
+	}
+	job := (*jobs)[0]
+	want := map[string]string{"severity": "critical", "name": "DiskFull"}
+	if job.Workflow != "page" || job.Vars.EventType != "firing" || !reflect.DeepEqual(job.Vars.Fields, want) {
+		t.Errorf("unexpected job %q with vars %+v", job.Workflow, job.Vars)
+	}
+
+	t.Run("hmac", func(t *testing.T) {
+		src := config.SourceConfig{Auth: "hmac", Secret: "k", Header: "X-Signature"}
+		body := []byte(`{}`)
+		header := http.Header{}
+		header.Set("X-Signature", computeHMAC(string(body), "k")[len("sha256="):])
+		if !authenticate(src, header, body) {
+			t.Error("expected bare hex signature to be accepted")
+		}
+		header.Set("X-Signature", computeHMAC(string(body), "other"))
+		if authenticate(src, header, body) {
+			t.Error("expected signature with the wrong secret to be rejected")
+		}
+	})
+}
+
+func postGitLab(t *testing.T, handler http.HandlerFunc, token, eventType, file string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/webhook/gitlab", bytes.NewReader(body))
+	req.Header.Set("X-Gitlab-Token", token)
+	req.Header.Set("X-Gitlab-Event", eventType)
+	req.Header.Set("X-Gitlab-Event-UUID", eventType+"/"+file)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestGitLab(t *testing.T) {
+	token := "gitlab-token"
+	cfg := &config.Config{
+		WebhookSecret: "test-secret",
+		Port:          7890,
+		GitLab:        config.GitLabConfig{WebhookToken: token},
+		Workflows: map[string]config.WorkflowConfig{
+			"review": {
+				Events:  []string{"issue_comment"},
+				Actions: []string{"created", "edited"},
+				Trigger: `^/cc review`,
+				Command: "echo review",
+				Timeout: 5,
+			},
+			"hello": {
+				Events:  []string{"issue_comment"},
+				Actions: []string{"created", "edited"},
+				Trigger: `hello`,
+				Command: "echo hello",
+				Timeout: 5,
+			},
+			"merged": {
+				Events:  []string{"pull_request"},
+				Labels:  []string{"api"},
+				Trigger: `^closed:merged$`,
+				Command: "echo merged",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	j := journal.Open(t.TempDir())
+	handler := GitLabHandler(cfg, Options{Queue: q, Journal: j})
+
+	if w := postGitLab(t, handler, "wrong", "Note Hook", "gitlab_note_merge_request.json"); w.Code != http.StatusForbidden {
+		t.Errorf("wrong token: expected 403, got %d", w.Code)
+	}
+	if w := postGitLab(t, Handler(cfg, Options{Queue: q}), "wrong", "Note Hook", "gitlab_note_merge_request.json"); w.Code != http.StatusForbidden {
+		t.Errorf("wrong token on /webhook: expected 403, got %d", w.Code)
+	}
+
+	tests := []struct {
+		eventType, file string
+		want            int
+	}{
+		{"Note Hook", "gitlab_note_merge_request.json", http.StatusAccepted},
+		// An edited note carries no previous text, so it cannot newly match.
+		{"Note Hook", "gitlab_note_issue.json", http.StatusOK},
+		{"Merge Request Hook", "gitlab_merge_request.json", http.StatusAccepted},
+		{"Pipeline Hook", "gitlab_merge_request.json", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := postGitLab(t, handler, token, tt.eventType, tt.file); w.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d (%s)", tt.eventType, tt.file, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	if len(*jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(*jobs))
+	}
+	want := []workflow.TemplateVars{
+		{
+			RepoFullName:  "gitlabhq/gitlab-test",
+			RepoCloneURL:  "http://example.com/gitlabhq/gitlab-test.git",
+			PRNumber:      "1",
+			CommentBody:   "/cc review --focus security",
+			CommentAuthor: "root",
+			CommentID:     "1244",
+			EventType:     "issue_comment",
+			Provider:      "gitlab",
+			HeadSHA:       "562e173be03b8ff2efb05345d12df18815438a4b",
+			IssueTitle:    "Tempora et eos debitis quae laborum et.",
+			HeadBranch:    "master",
+			HTMLURL:       "http://example.com/gitlab-org/gitlab-test/merge_requests/1#note_1244",
+		},
+		{
+			RepoFullName: "gitlabhq/gitlab-test",
+			RepoCloneURL: "http://example.com/gitlabhq/gitlab-test.git",
+			PRNumber:     "1",
+			EventType:    "pull_request",
+			Provider:     "gitlab",
+			HeadSHA:      "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+			IssueTitle:   "MS-Viewport",
+			HeadBranch:   "ms-viewport",
+			HTMLURL:      "http://example.com/diaspora/merge_requests/1",
+		},
+	}
+	for i, job := range *jobs {
+		if !reflect.DeepEqual(job.Vars, want[i]) {
+			t.Errorf("job %d (%s): vars = %+v\nwant %+v", i, job.Workflow, job.Vars, want[i])
+		}
+	}
+
+	entry, err := j.Find("Merge Request Hook/gitlab_merge_request.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Provider != "gitlab" || entry.Event != "Merge Request Hook" {
+		t.Errorf("unexpected journal entry: provider %q, event %q", entry.Provider, entry.Event)
+	}
+	if got := entry.Headers.Get("X-Gitlab-Token"); got != "" {
+		t.Errorf("journal entry kept X-Gitlab-Token %q", got)
+	}
+	workflows, err := Replay(cfg, Options{Queue: q}, entry)
+	if err != nil || len(workflows) != 1 || workflows[0] != "merged" {
+		t.Errorf("replay matched %v (%v), want [merged]", workflows, err)
+	}
+}
+
+func postGitea(t *testing.T, handler http.HandlerFunc, secret, prefix, eventType, file string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(body))
+	// Gitea also sends GitHub's headers, with the signature GitHub would send.
+	req.Header.Set("X-GitHub-Event", eventType)
+	req.Header.Set("X-Hub-Signature-256", computeHMAC(string(body), "github-secret"))
+	req.Header.Set("X-"+prefix+"-Event", eventType)
+	req.Header.Set("X-"+prefix+"-Delivery", prefix+"/"+file)
+	req.Header.Set("X-"+prefix+"-Signature", strings.TrimPrefix(computeHMAC(string(body), secret), "sha256="))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestGitea(t *testing.T) {
+	secret := "gitea-secret"
+	cfg := &config.Config{
+		WebhookSecret: "github-secret",
+		Port:          7890,
+		Gitea:         config.GiteaConfig{WebhookSecret: secret},
+		Workflows: map[string]config.WorkflowConfig{
+			"review": {
+				Events:  config.DefaultEvents,
+				Trigger: `/cc\s+@claude`,
+				Command: "echo review",
+				Timeout: 5,
+			},
+			"deps": {
+				Events:  []string{"pull_request"},
+				Actions: []string{"synchronize"},
+				Labels:  []string{"deps"},
+				Trigger: `.*`,
+				Command: "echo deps",
+				Timeout: 5,
+			},
+		},
+	}
+	q, jobs := newRecordingQueue(cfg)
+	handler := Handler(cfg, Options{Queue: q})
+
+	tests := []struct {
+		name                            string
+		handler                         http.HandlerFunc
+		secret, prefix, eventType, file string
+		want                            int
+	}{
+		{"gitea comment", handler, secret, "Gitea", "issue_comment", "gitea_issue_comment.json", http.StatusAccepted},
+		{"forgejo pull request", handler, secret, "Forgejo", "pull_request", "gitea_pull_request.json", http.StatusAccepted},
+		{"gitea endpoint", GiteaHandler(cfg, Options{Queue: q}), secret, "Gitea", "issue_comment", "gitea_issue_comment.json", http.StatusAccepted},
+		{"wrong secret", handler, "wrong", "Gitea", "issue_comment", "gitea_issue_comment.json", http.StatusForbidden},
+		{"unsupported event", handler, secret, "Gitea", "push", "gitea_pull_request.json", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postGitea(t, tt.handler, tt.secret, tt.prefix, tt.eventType, tt.file)
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d (%s)", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if len(*jobs) != 3 {
+		t.Fatalf("expected 3 jobs, got %d", len(*jobs))
+	}
+	comment, pr := (*jobs)[0], (*jobs)[1]
+	if comment.Workflow != "review" || comment.Vars.Provider != "gitea" || comment.Vars.RepoFullName != "homelab/infra" ||
+		comment.Vars.PRNumber != "7" || comment.Vars.CommentAuthor != "bob" || comment.Vars.CommentID != "112" {
+		t.Errorf("unexpected comment job %q: %+v", comment.Workflow, comment.Vars)
+	}
+	if pr.Workflow != "deps" || pr.Vars.Provider != "gitea" || pr.Vars.HeadSHA != "9f8e7d6c5b4a39281706f5e4d3c2b1a098765432" {
+		t.Errorf("unexpected pull request job %q: %+v", pr.Workflow, pr.Vars)
+	}
+}
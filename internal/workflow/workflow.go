@@ -3,6 +3,8 @@ package workflow
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -20,7 +22,46 @@ type TemplateVars struct {
 	PRNumber      string
 	CommentBody   string
 	CommentAuthor string
+	CommentID     string
 	EventType     string
+	Provider      string
+	HeadSHA       string
+	Ref           string
+	BeforeSHA     string
+	AfterSHA      string
+	Pusher        string
+	Label         string
+	IssueTitle    string
+	RunName       string
+	Conclusion    string
+	HeadBranch    string
+	HTMLURL       string
+	TagName       string
+	ReleaseName   string
+	Environment   string
+	DeploymentID  string
+
+	DiscussionNumber   string
+	DiscussionCategory string
+	DiscussionTitle    string
+
+	// Match holds the trigger's named capture groups.
+	Match map[string]string
+	// Fields holds the values extracted from a source delivery.
+	Fields map[string]string
+
+	// Set for workflows with a command_name.
+	Subcommand string
+	Args       Args
+	Flags      map[string]string
+}
+
+// Args are a slash command's positional arguments. They render space
+// separated, and {{index .Args 0}} picks out one.
+type Args []string
+
+func (a Args) String() string {
+	return strings.Join(a, " ")
 }
 
 var shellMetaChars = regexp.MustCompile(`[;&|$` + "`" + `\\!(){}\[\]<>*?~#'"\n\r]`)
@@ -30,14 +71,57 @@ func Sanitize(input string) string {
 }
 
 func SanitizeVars(vars TemplateVars) TemplateVars {
+	var args Args
+	for _, a := range vars.Args {
+		args = append(args, Sanitize(a))
+	}
 	return TemplateVars{
 		RepoFullName:  Sanitize(vars.RepoFullName),
 		RepoCloneURL:  Sanitize(vars.RepoCloneURL),
 		PRNumber:      Sanitize(vars.PRNumber),
 		CommentBody:   Sanitize(vars.CommentBody),
 		CommentAuthor: Sanitize(vars.CommentAuthor),
+		CommentID:     Sanitize(vars.CommentID),
 		EventType:     Sanitize(vars.EventType),
+		Provider:      Sanitize(vars.Provider),
+		HeadSHA:       Sanitize(vars.HeadSHA),
+		Ref:           Sanitize(vars.Ref),
+		BeforeSHA:     Sanitize(vars.BeforeSHA),
+		AfterSHA:      Sanitize(vars.AfterSHA),
+		Pusher:        Sanitize(vars.Pusher),
+		Label:         Sanitize(vars.Label),
+		IssueTitle:    Sanitize(vars.IssueTitle),
+		RunName:       Sanitize(vars.RunName),
+		Conclusion:    Sanitize(vars.Conclusion),
+		HeadBranch:    Sanitize(vars.HeadBranch),
+		HTMLURL:       Sanitize(vars.HTMLURL),
+		TagName:       Sanitize(vars.TagName),
+		ReleaseName:   Sanitize(vars.ReleaseName),
+		Environment:   Sanitize(vars.Environment),
+		DeploymentID:  Sanitize(vars.DeploymentID),
+
+		DiscussionNumber:   Sanitize(vars.DiscussionNumber),
+		DiscussionCategory: Sanitize(vars.DiscussionCategory),
+		DiscussionTitle:    Sanitize(vars.DiscussionTitle),
+
+		Match:  sanitizeMap(vars.Match),
+		Fields: sanitizeMap(vars.Fields),
+
+		Subcommand: Sanitize(vars.Subcommand),
+		Args:       args,
+		Flags:      sanitizeMap(vars.Flags),
+	}
+}
+
+func sanitizeMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	safe := make(map[string]string, len(m))
+	for k, v := range m {
+		safe[k] = Sanitize(v)
 	}
+	return safe
 }
 
 func RenderTemplate(tmpl string, vars TemplateVars) (string, error) {
@@ -52,13 +136,50 @@ func RenderTemplate(tmpl string, vars TemplateVars) (string, error) {
 	return buf.String(), nil
 }
 
-func Execute(name string, wf config.WorkflowConfig, vars TemplateVars) {
+// commandEnv exposes a slash command as HR_SUBCOMMAND, HR_ARGS, HR_ARG_1..n
+// for positional arguments and HR_ARG_<FLAG> for flags, upper-cased with
+// dashes turned into underscores.
+func commandEnv(vars TemplateVars) []string {
+	if vars.Subcommand == "" {
+		return nil
+	}
+	env := []string{"HR_SUBCOMMAND=" + vars.Subcommand, "HR_ARGS=" + vars.Args.String()}
+	for i, a := range vars.Args {
+		env = append(env, fmt.Sprintf("HR_ARG_%d=%s", i+1, a))
+	}
+	for k, v := range vars.Flags {
+		env = append(env, "HR_ARG_"+strings.ToUpper(strings.ReplaceAll(k, "-", "_"))+"="+v)
+	}
+	return env
+}
+
+// Result describes how a workflow run ended. ExitCode is -1 when the command
+// never ran or was killed.
+type Result struct {
+	Start    time.Time
+	End      time.Time
+	ExitCode int
+	TimedOut bool
+	Canceled bool
+	Err      error
+}
+
+func (r Result) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Execute runs the workflow's command, streaming its combined stdout and
+// stderr to out.
+func Execute(ctx context.Context, name string, wf config.WorkflowConfig, vars TemplateVars, out io.Writer) Result {
+	res := Result{Start: time.Now(), ExitCode: -1}
 	safe := SanitizeVars(vars)
 
 	cmd, err := RenderTemplate(wf.Command, safe)
 	if err != nil {
 		log.Printf("Workflow %q: template error: %v", name, err)
-		return
+		res.End = time.Now()
+		res.Err = fmt.Errorf("template error: %w", err)
+		return res
 	}
 
 	workdir := ""
@@ -66,19 +187,22 @@ func Execute(name string, wf config.WorkflowConfig, vars TemplateVars) {
 		workdir, err = RenderTemplate(wf.Workdir, safe)
 		if err != nil {
 			log.Printf("Workflow %q: workdir template error: %v", name, err)
-			return
+			res.End = time.Now()
+			res.Err = fmt.Errorf("workdir template error: %w", err)
+			return res
 		}
 		workdir = config.ExpandTilde(workdir)
 	}
 
 	timeout := time.Duration(wf.Timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	log.Printf("────── Workflow %q started ──────", name)
 	log.Printf("Command: %s", cmd)
-
-	start := time.Now()
+	if f, ok := out.(interface{ Name() string }); ok {
+		log.Printf("Output: %s", f.Name())
+	}
 
 	proc := exec.CommandContext(ctx, "sh", "-c", cmd)
 	proc.Env = append(os.Environ(),
@@ -86,33 +210,73 @@ func Execute(name string, wf config.WorkflowConfig, vars TemplateVars) {
 		"HR_REPO="+vars.RepoFullName,
 		"HR_COMMENT_BODY="+vars.CommentBody,
 		"HR_COMMENT_AUTHOR="+vars.CommentAuthor,
+		"HR_COMMENT_ID="+vars.CommentID,
 		"HR_EVENT_TYPE="+vars.EventType,
+		"HR_PROVIDER="+vars.Provider,
+		"HR_HEAD_SHA="+vars.HeadSHA,
+		"HR_REF="+vars.Ref,
+		"HR_BEFORE_SHA="+vars.BeforeSHA,
+		"HR_AFTER_SHA="+vars.AfterSHA,
+		"HR_PUSHER="+vars.Pusher,
+		"HR_LABEL="+vars.Label,
+		"HR_ISSUE_TITLE="+vars.IssueTitle,
+		"HR_RUN_NAME="+vars.RunName,
+		"HR_CONCLUSION="+vars.Conclusion,
+		"HR_HEAD_BRANCH="+vars.HeadBranch,
+		"HR_HTML_URL="+vars.HTMLURL,
+		"HR_TAG_NAME="+vars.TagName,
+		"HR_RELEASE_NAME="+vars.ReleaseName,
+		"HR_ENVIRONMENT="+vars.Environment,
+		"HR_DEPLOYMENT_ID="+vars.DeploymentID,
+		"HR_DISCUSSION_NUMBER="+vars.DiscussionNumber,
+		"HR_DISCUSSION_CATEGORY="+vars.DiscussionCategory,
+		"HR_DISCUSSION_TITLE="+vars.DiscussionTitle,
 	)
+	for k, v := range vars.Match {
+		proc.Env = append(proc.Env, "HR_MATCH_"+strings.ToUpper(k)+"="+v)
+	}
+	for k, v := range vars.Fields {
+		proc.Env = append(proc.Env, "HR_FIELD_"+strings.ToUpper(k)+"="+v)
+	}
+	proc.Env = append(proc.Env, commandEnv(vars)...)
 	if workdir != "" {
 		proc.Dir = workdir
 	}
+	proc.Stdout = out
+	proc.Stderr = out
 
-	output, err := proc.CombinedOutput()
-	duration := time.Since(start)
-	outStr := strings.TrimSpace(string(output))
+	err = proc.Run()
+	res.End = time.Now()
+	res.Err = err
+	if proc.ProcessState != nil {
+		res.ExitCode = proc.ProcessState.ExitCode()
+	}
+	duration := res.Duration()
 
 	if ctx.Err() == context.DeadlineExceeded {
+		res.TimedOut = true
 		log.Printf("Workflow %q: timed out after %ds", name, wf.Timeout)
 		log.Printf("────── Workflow %q timed out (%.1fs) ──────", name, duration.Seconds())
 		log.Printf("════════════════════════════════════════")
-		return
+		return res
+	}
+
+	if ctx.Err() == context.Canceled {
+		res.Canceled = true
+		log.Printf("Workflow %q: canceled: %v", name, context.Cause(ctx))
+		log.Printf("────── Workflow %q canceled (%.1fs) ──────", name, duration.Seconds())
+		log.Printf("════════════════════════════════════════")
+		return res
 	}
 
 	if err != nil {
-		log.Printf("Workflow %q: error: %v\nOutput: %s", name, err, outStr)
+		log.Printf("Workflow %q: error: %v", name, err)
 		log.Printf("────── Workflow %q failed (%.1fs) ──────", name, duration.Seconds())
 		log.Printf("════════════════════════════════════════")
-		return
+		return res
 	}
 
-	if outStr != "" {
-		log.Printf("Output: %s", outStr)
-	}
 	log.Printf("────── Workflow %q completed (%.1fs) ──────", name, duration.Seconds())
 	log.Printf("════════════════════════════════════════")
+	return res
 }
@@ -59,6 +59,28 @@ func TestRenderTemplate(t *testing.T) {
 		}
 	})
 
+	t.Run("command arguments", func(t *testing.T) {
+		vars := TemplateVars{Subcommand: "review", Args: Args{"src/", "pkg/"}, Flags: map[string]string{"model": "opus"}}
+		result, err := RenderTemplate("{{.Subcommand}} {{.Args}} {{index .Args 1}} {{.Flags.model}}", vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != "review src/ pkg/ pkg/ opus" {
+			t.Errorf("got %q", result)
+		}
+	})
+
+	t.Run("capture groups", func(t *testing.T) {
+		vars := TemplateVars{Match: map[string]string{"target": "api"}}
+		result, err := RenderTemplate("deploy {{.Match.target}}", vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != "deploy api" {
+			t.Errorf("got %q", result)
+		}
+	})
+
 	t.Run("invalid template", func(t *testing.T) {
 		_, err := RenderTemplate("{{.Invalid", vars)
 		if err == nil {
@@ -72,6 +94,9 @@ func TestSanitizeVars(t *testing.T) {
 		RepoFullName:  "org/repo",
 		CommentBody:   "hello; rm -rf /",
 		CommentAuthor: "alice$(whoami)",
+		Args:          Args{"src$(whoami)"},
+		Flags:         map[string]string{"model": "opus; rm -rf /"},
+		Match:         map[string]string{"target": "api`id`"},
 	}
 
 	safe := SanitizeVars(vars)
@@ -82,6 +107,15 @@ func TestSanitizeVars(t *testing.T) {
 	if safe.CommentAuthor != "alicewhoami" {
 		t.Errorf("CommentAuthor not sanitized: %q", safe.CommentAuthor)
 	}
+	if safe.Args[0] != "srcwhoami" || safe.Flags["model"] != "opus rm -rf /" {
+		t.Errorf("command arguments not sanitized: %q %v", safe.Args, safe.Flags)
+	}
+	if safe.Match["target"] != "apiid" {
+		t.Errorf("capture group not sanitized: %q", safe.Match["target"])
+	}
+	if vars.Flags["model"] != "opus; rm -rf /" {
+		t.Errorf("SanitizeVars modified the original flags: %v", vars.Flags)
+	}
 	if safe.RepoFullName != "org/repo" {
 		t.Errorf("RepoFullName should be unchanged: %q", safe.RepoFullName)
 	}